@@ -0,0 +1,366 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backend defines the capabilities a storage backend may implement,
+// and a registry that maps URI schemes onto backends.
+//
+// Backends register themselves from an init function, so a program must
+// import the backends it wants to use, typically for side effects only:
+//
+//	import _ "github.com/kurin/cloudpipe/backends/b2"
+package backend
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An Endpoint is a location within a backend, such as an object or a bucket.
+// What can be done with an endpoint is discovered by asserting it against
+// the capability interfaces below.
+type Endpoint interface{}
+
+// Reader is implemented by endpoints that can be read from.
+type Reader interface {
+	Reader(context.Context) (io.ReadCloser, error)
+}
+
+// RangeReader is implemented by endpoints that can read part of an object,
+// starting offset bytes in.  A negative length reads to the end.
+type RangeReader interface {
+	RangeReader(ctx context.Context, offset, length int64) (io.ReadCloser, error)
+}
+
+// Writer is implemented by endpoints that can be written to.  The object is
+// committed when the writer is closed, unless the context passed to Writer
+// has been cancelled by then, in which case the write is abandoned and Close
+// returns an error.
+type Writer interface {
+	Writer(context.Context) (io.WriteCloser, error)
+}
+
+// Copier is implemented by endpoints that can copy another object onto
+// themselves without the data passing through this program.  CopyFrom
+// returns an UnsupportedError if it can't copy from src, such as when src is
+// in another backend.
+type Copier interface {
+	CopyFrom(ctx context.Context, src Endpoint) error
+}
+
+// Labeler is implemented by endpoints that can attach key=value labels to
+// the objects they write.
+type Labeler interface {
+	Label(string)
+}
+
+// Lister is implemented by endpoints that can list the objects beneath them.
+// Objects are streamed over the first channel; at most one error is sent on
+// the second.  Both channels are closed when listing is complete.  Unless
+// Options.Detail was set, listed objects may have only their Name and Prefix
+// fields filled in.
+type Lister interface {
+	List(context.Context) (chan *Attrs, chan error, error)
+}
+
+// Remover is implemented by endpoints that can be deleted.
+type Remover interface {
+	Remove(context.Context) error
+}
+
+// BucketAttrs holds the settings for a new bucket.  Backends ignore any
+// settings that don't apply to them.
+type BucketAttrs struct {
+	// Public allows anyone to read the bucket's objects (b2, az).
+	Public bool
+
+	// Project is the project that owns the bucket, if not the project of
+	// the key file (gcs).
+	Project string
+
+	// Location is where the bucket's data is kept, such as "US" (gcs) or
+	// "eu-west-1" (s3).
+	Location string
+
+	// StorageClass is the default storage class of the bucket's objects,
+	// such as "NEARLINE" (gcs).
+	StorageClass string
+}
+
+// A BucketMaker is an endpoint that can create the bucket it is in.
+type BucketMaker interface {
+	MakeBucket(context.Context, *BucketAttrs) error
+}
+
+// A BucketRemover is an endpoint that can delete the bucket it is in.  Some
+// backends refuse to delete a bucket that isn't empty; others delete its
+// contents along with it.
+type BucketRemover interface {
+	RemoveBucket(context.Context) error
+}
+
+// A Namespace is an endpoint that can open other objects in the same bucket
+// or filesystem, sharing its client and options.
+type Namespace interface {
+	// Name returns the endpoint's own object name, or prefix.
+	Name() string
+
+	// Object returns an endpoint for the named object.  Names are as
+	// reported by Lister.
+	Object(name string) Endpoint
+}
+
+// RemoveStats counts the objects affected by a removal.
+type RemoveStats struct {
+	Deleted int64
+	Hidden  int64
+	Failed  int64
+}
+
+func (s RemoveStats) String() string {
+	return fmt.Sprintf("%d deleted, %d hidden, %d failed", s.Deleted, s.Hidden, s.Failed)
+}
+
+// A StatsRemover is a Remover that can report what its last removal did.
+type StatsRemover interface {
+	Remover
+	RemoveStats() RemoveStats
+}
+
+// Attrs describes a single object.  Backends leave empty any field they
+// don't support.
+type Attrs struct {
+	Name        string
+	Size        int64
+	ContentType string
+
+	// Prefix is set for the common prefixes, or directories, that listings
+	// report in place of the objects beneath them.  Only Name is set.
+	Prefix bool
+
+	// Version identifies this version of the object, for backends that keep
+	// more than one.
+	Version string
+
+	// Hider is set for B2 hide markers, which are versions that hide the
+	// object's name rather than hold its contents.
+	Hider bool
+
+	// Latest is set, in listings that include old versions, for the newest
+	// version of each object, which is current unless it is a hider.
+	Latest bool
+
+	// Uploaded is when the object was created in the backend.
+	Uploaded time.Time
+
+	// LastModified is when the object's contents were last modified, which
+	// for uploaded files may be well before they were uploaded.
+	LastModified time.Time
+
+	// Checksums of the object's contents, in lowercase hex.
+	SHA1   string
+	MD5    string
+	CRC32C string
+
+	// Metadata holds user-supplied key=value pairs, such as those set with
+	// Labeler.
+	Metadata map[string]string
+}
+
+// Checksum returns the named checksum from a, or "" if there is no such
+// checksum.
+func (a *Attrs) Checksum(name string) string {
+	switch name {
+	case "sha1":
+		return a.SHA1
+	case "md5":
+		return a.MD5
+	case "crc32c":
+		return a.CRC32C
+	}
+	return ""
+}
+
+// NewHash returns a hash for the named checksum, one of "sha1", "md5", or
+// "crc32c".  The hex encoding of its sum matches the corresponding Attrs
+// field.
+func NewHash(name string) (hash.Hash, error) {
+	switch name {
+	case "sha1":
+		return sha1.New(), nil
+	case "md5":
+		return md5.New(), nil
+	case "crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	}
+	return nil, fmt.Errorf("%s: unknown checksum", name)
+}
+
+// A Checksummer is a Statter that names the checksums its Stat reports for
+// objects written to it.
+type Checksummer interface {
+	Statter
+	Checksums() []string
+}
+
+// A Sizer is an endpoint that can report the size of its object more cheaply
+// than Stat.
+type Sizer interface {
+	Size(context.Context) (int64, error)
+}
+
+// A ChunkReporter is an endpoint that transfers objects in chunks, and can
+// report the progress of each chunk currently in flight as a fraction
+// between 0 and 1.
+type ChunkReporter interface {
+	Chunks() []float64
+}
+
+// Statter is implemented by endpoints that can describe themselves.
+type Statter interface {
+	Stat(context.Context) (*Attrs, error)
+}
+
+// Options holds the settings a command passes to a backend.  Backends ignore
+// any options that don't apply to them.
+type Options struct {
+	// Auth is the path to a credentials file (gcs), or to an SSH private key
+	// to try after those held by the agent (sftp).
+	Auth string
+
+	// Connections is the number of simultaneous connections, or requests in
+	// flight, to use (b2, s3, az, sftp, http).
+	Connections int
+
+	// PartSize is the size in bytes of each part of a multipart upload, or
+	// zero for the backend's default (s3, az).  Parallel downloads fetch
+	// ranges of this size (http).
+	PartSize int64
+
+	// Resume controls whether an interrupted upload is resumed (b2).
+	Resume bool
+
+	// Hide causes removals to hide objects instead of deleting them (b2).
+	Hide bool
+
+	// Hidden includes hidden objects in lists and removals (b2, s3, az).
+	Hidden bool
+
+	// Recursive causes listings and removals to apply to everything under a
+	// path, rather than to a single object or directory level.
+	Recursive bool
+
+	// AllVersions causes removals to delete every version of an object, not
+	// just the most recent (b2, s3).
+	AllVersions bool
+
+	// Threads is the number of workers removing objects in parallel (b2, s3,
+	// az).
+	Threads int
+
+	// Checksum names the checksum that Stat computes for local files, one of
+	// "sha1", "md5", or "crc32c" (file).
+	Checksum string
+
+	// Headers are added to every request (http).
+	Headers Headers
+
+	// Detail asks listings to fill in every attribute they can, which may
+	// cost an extra request per object (b2, s3).
+	Detail bool
+}
+
+// Headers is a flag.Value that collects "Name: value" HTTP headers.
+type Headers []string
+
+func (h *Headers) String() string { return strings.Join(*h, ", ") }
+
+func (h *Headers) Set(s string) error {
+	if i := strings.Index(s, ":"); i < 1 {
+		return fmt.Errorf("%q: want Name: value", s)
+	}
+	*h = append(*h, s)
+	return nil
+}
+
+// An OpenFunc returns an endpoint for the given URI.
+type OpenFunc func(ctx context.Context, uri *url.URL, opts *Options) (Endpoint, error)
+
+var (
+	mu       sync.RWMutex
+	backends = make(map[string]OpenFunc)
+)
+
+// Register makes a backend available for the given URI scheme.  It panics if
+// open is nil or if the scheme is already registered.
+func Register(scheme string, open OpenFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	if open == nil {
+		panic("backend: Register open func is nil")
+	}
+	if _, ok := backends[scheme]; ok {
+		panic("backend: Register called twice for scheme " + scheme)
+	}
+	backends[scheme] = open
+}
+
+// Schemes returns a sorted list of the registered URI schemes.
+func Schemes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	var s []string
+	for scheme := range backends {
+		s = append(s, scheme)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// Open parses uri and returns an endpoint from the backend registered for its
+// scheme.  A nil opts is treated as the zero Options.
+func Open(ctx context.Context, uri string, opts *Options) (Endpoint, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	mu.RLock()
+	open, ok := backends[u.Scheme]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown scheme", u.Scheme)
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	return open(ctx, u, opts)
+}
+
+// UnsupportedError is returned when an endpoint lacks a capability.
+type UnsupportedError struct {
+	Op string
+}
+
+func (e UnsupportedError) Error() string {
+	return fmt.Sprintf("%s is not supported by this backend", e.Op)
+}
@@ -0,0 +1,553 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package az implements the endpoint interface for Azure Blob Storage.  URIs
+// have the form az://account/container/blob.
+package az
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/status"
+)
+
+func init() {
+	backend.Register("az", open)
+}
+
+// Endpoint is a blob, prefix, or container in an Azure storage account.
+type Endpoint struct {
+	// Connections is the number of blocks to stage at once when uploading.
+	Connections int
+
+	// PartSize is the size in bytes of each block staged when uploading.
+	PartSize int64
+
+	Hidden    bool
+	Recursive bool
+	Threads   int
+
+	client    *container.Client
+	account   string
+	container string
+	path      string
+	m         map[string]*string
+	stats     backend.RemoveStats
+}
+
+// Account holds the settings for one storage account, saved by the azconfig
+// command.
+type Account struct {
+	// Key is the account's shared key.  If it is empty, the
+	// AZURE_STORAGE_KEY environment variable is used, and failing that,
+	// requests are made anonymously.
+	Key string `json:"key,omitempty"`
+
+	// Endpoint is the account's blob service URL, such as
+	// "http://127.0.0.1:10000/devstoreaccount1" for the Azurite emulator.
+	// If it is empty, the public Azure endpoint is used.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Config maps account names onto their settings.
+type Config struct {
+	Accounts map[string]*Account `json:"accounts"`
+}
+
+func configPath() (string, error) {
+	u, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(u.HomeDir, ".cloudpipe_az"), nil
+}
+
+// LoadConfig returns the saved configuration, or an empty one if none has
+// been saved.
+func LoadConfig() (*Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{Accounts: make(map[string]*Account)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(c); err != nil {
+		return nil, err
+	}
+	if c.Accounts == nil {
+		c.Accounts = make(map[string]*Account)
+	}
+	return c, nil
+}
+
+func Save(c *Config) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(c); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// timer records every request made by a client with the status package.
+// Requests are named by method and, where there is one, by the "comp" query
+// parameter that selects the operation, as in "az.put.block".
+type timer struct{}
+
+func (timer) Do(req *policy.Request) (*http.Response, error) {
+	r := req.Raw()
+	method := "az." + strings.ToLower(r.Method)
+	if comp := r.URL.Query().Get("comp"); comp != "" {
+		method += "." + comp
+	}
+	defer status.Time(method)()
+	return req.Next()
+}
+
+func New(ctx context.Context, uri *url.URL) (*Endpoint, error) {
+	account := uri.Host
+	parts := strings.SplitN(strings.TrimPrefix(uri.Path, "/"), "/", 2)
+	if account == "" || parts[0] == "" {
+		return nil, fmt.Errorf("%s: want az://account/container/blob", uri)
+	}
+	ep := &Endpoint{
+		account:   account,
+		container: parts[0],
+	}
+	if len(parts) > 1 {
+		ep.path = parts[1]
+	}
+
+	c, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	acct := c.Accounts[account]
+	if acct == nil {
+		acct = &Account{}
+	}
+	svc := acct.Endpoint
+	if svc == "" {
+		svc = "https://" + account + ".blob.core.windows.net"
+	}
+	key := acct.Key
+	if key == "" {
+		key = os.Getenv("AZURE_STORAGE_KEY")
+	}
+
+	u := strings.TrimSuffix(svc, "/") + "/" + ep.container
+	opts := &container.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			PerRetryPolicies: []policy.Policy{timer{}},
+		},
+	}
+	if key == "" {
+		ep.client, err = container.NewClientWithNoCredential(u, opts)
+	} else {
+		var cred *container.SharedKeyCredential
+		cred, err = container.NewSharedKeyCredential(account, key)
+		if err != nil {
+			return nil, err
+		}
+		ep.client, err = container.NewClientWithSharedKeyCredential(u, cred, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ep, nil
+}
+
+func open(ctx context.Context, uri *url.URL, opts *backend.Options) (backend.Endpoint, error) {
+	ep, err := New(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	ep.Connections = opts.Connections
+	ep.PartSize = opts.PartSize
+	ep.Hidden = opts.Hidden
+	ep.Recursive = opts.Recursive
+	ep.Threads = opts.Threads
+	return ep, nil
+}
+
+// Name returns the endpoint's blob name.
+func (e *Endpoint) Name() string { return e.path }
+
+// Object returns an endpoint for another blob in the same container.
+func (e *Endpoint) Object(name string) backend.Endpoint {
+	ep := *e
+	ep.path = name
+	ep.stats = backend.RemoveStats{}
+	return &ep
+}
+
+func (e *Endpoint) uri() string {
+	return "az://" + e.account + "/" + e.container + "/" + e.path
+}
+
+// Writer uploads to the endpoint's blob as a block blob, staging up to
+// Connections blocks of PartSize bytes at once.
+func (e *Endpoint) Writer(ctx context.Context) (io.WriteCloser, error) {
+	bb := e.client.NewBlockBlobClient(e.path)
+	opts := &blockblob.UploadStreamOptions{
+		BlockSize:   e.PartSize,
+		Concurrency: e.Connections,
+		Metadata:    e.m,
+	}
+	pr, pw := io.Pipe()
+	w := &writer{ctx: ctx, pw: pw, done: make(chan struct{})}
+	go func() {
+		_, err := bb.UploadStream(ctx, pr, opts)
+		// If the upload failed, this makes further writes fail too.
+		pr.CloseWithError(err)
+		w.err = err
+		close(w.done)
+	}()
+	return status.TrackWriter(e.uri(), w, nil), nil
+}
+
+type writer struct {
+	ctx  context.Context
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+func (w *writer) Write(p []byte) (int, error) { return w.pw.Write(p) }
+
+// Close finishes the upload, or if the context was cancelled, fails it so
+// that nothing is committed.
+func (w *writer) Close() error {
+	if err := w.ctx.Err(); err != nil {
+		w.pw.CloseWithError(err)
+		<-w.done
+		return err
+	}
+	w.pw.Close()
+	<-w.done
+	return w.err
+}
+
+func (e *Endpoint) Reader(ctx context.Context) (io.ReadCloser, error) {
+	resp, err := e.client.NewBlobClient(e.path).DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return status.TrackReader(e.uri(), resp.Body, nil), nil
+}
+
+// Label sets the metadata of blobs written to the endpoint.  Azure requires
+// metadata names to be valid C# identifiers.
+func (e *Endpoint) Label(l string) {
+	labels := strings.Split(l, ",")
+	m := make(map[string]*string)
+	for _, label := range labels {
+		i := strings.Index(label, "=")
+		if i < 0 {
+			continue
+		}
+		key, val := strings.Trim(label[:i], " "), strings.Trim(label[i+1:], " ")
+		m[key] = &val
+	}
+	e.m = m
+}
+
+// List sends the blobs and "directories" immediately beneath the endpoint's
+// path, or if Recursive is set, every blob beneath it.  If Hidden is set,
+// every version and soft-deleted blob is sent too, with deleted blobs as
+// hiders.  Azure lists every attribute, so Detail costs nothing.
+func (e *Endpoint) List(ctx context.Context) (chan *backend.Attrs, chan error, error) {
+	ach := make(chan *backend.Attrs)
+	ech := make(chan error, 1)
+
+	go func() {
+		defer close(ach)
+		defer close(ech)
+
+		err := e.walk(ctx, !e.Recursive, e.Hidden, func(a *backend.Attrs) error {
+			ach <- a
+			return nil
+		})
+		if err != nil {
+			ech <- err
+		}
+	}()
+
+	return ach, ech, nil
+}
+
+// walk calls fn for each blob beneath the endpoint's path, and if delim is
+// set, for each prefix ending in "/".  If hidden is set, versions and
+// soft-deleted blobs are included.
+func (e *Endpoint) walk(ctx context.Context, delim, hidden bool, fn func(*backend.Attrs) error) error {
+	include := container.ListBlobsInclude{
+		Metadata: true,
+		Deleted:  hidden,
+		Versions: hidden,
+	}
+	prefix := &e.path
+	if e.path == "" {
+		prefix = nil
+	}
+
+	if !delim {
+		p := e.client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: prefix, Include: include})
+		for p.More() {
+			page, err := p.NextPage(ctx)
+			if err != nil {
+				return err
+			}
+			for _, item := range page.Segment.BlobItems {
+				if err := fn(convertItem(item)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	p := e.client.NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{Prefix: prefix, Include: include})
+	for p.More() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, bp := range page.Segment.BlobPrefixes {
+			if err := fn(&backend.Attrs{Name: deref(bp.Name), Prefix: true}); err != nil {
+				return err
+			}
+		}
+		for _, item := range page.Segment.BlobItems {
+			if err := fn(convertItem(item)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Remove deletes the endpoint's blob, along with its snapshots.  If Recursive
+// is set, every blob beneath the endpoint's path is deleted instead, using
+// Threads workers.  Failures on individual blobs don't stop a recursive
+// removal; they are counted and reported when it completes.
+func (e *Endpoint) Remove(ctx context.Context) error {
+	e.stats = backend.RemoveStats{}
+	if !e.Recursive {
+		return e.remove(ctx, e.path)
+	}
+
+	threads := e.Threads
+	if threads < 1 {
+		threads = 1
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		first error
+	)
+	names := make(chan string)
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				if err := e.remove(ctx, name); err != nil {
+					mu.Lock()
+					if first == nil {
+						first = fmt.Errorf("%s: %v", name, err)
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	// Only current blobs are listed; deleting them removes their versions
+	// from view.
+	err := func() error {
+		defer close(names)
+		return e.walk(ctx, false, false, func(a *backend.Attrs) error {
+			select {
+			case names <- a.Name:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+
+	if failed := atomic.LoadInt64(&e.stats.Failed); failed > 0 {
+		return fmt.Errorf("%d objects could not be removed; first error: %v", failed, first)
+	}
+	return nil
+}
+
+// MakeBucket creates the endpoint's container.  Public containers allow
+// anonymous reads of their blobs.
+func (e *Endpoint) MakeBucket(ctx context.Context, attrs *backend.BucketAttrs) error {
+	var opts *container.CreateOptions
+	if attrs.Public {
+		access := container.PublicAccessTypeBlob
+		opts = &container.CreateOptions{Access: &access}
+	}
+	_, err := e.client.Create(ctx, opts)
+	return err
+}
+
+// RemoveBucket deletes the endpoint's container, along with any blobs left
+// in it.
+func (e *Endpoint) RemoveBucket(ctx context.Context) error {
+	_, err := e.client.Delete(ctx, nil)
+	return err
+}
+
+func (e *Endpoint) remove(ctx context.Context, name string) error {
+	include := blob.DeleteSnapshotsOptionTypeInclude
+	_, err := e.client.NewBlobClient(name).Delete(ctx, &blob.DeleteOptions{DeleteSnapshots: &include})
+	if err != nil {
+		atomic.AddInt64(&e.stats.Failed, 1)
+		return err
+	}
+	atomic.AddInt64(&e.stats.Deleted, 1)
+	return nil
+}
+
+// RemoveStats reports the objects affected by the last call to Remove.
+func (e *Endpoint) RemoveStats() backend.RemoveStats {
+	return backend.RemoveStats{
+		Deleted: atomic.LoadInt64(&e.stats.Deleted),
+		Hidden:  atomic.LoadInt64(&e.stats.Hidden),
+		Failed:  atomic.LoadInt64(&e.stats.Failed),
+	}
+}
+
+func (e *Endpoint) Stat(ctx context.Context) (*backend.Attrs, error) {
+	props, err := e.client.NewBlobClient(e.path).GetProperties(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	a := &backend.Attrs{
+		Name:        e.path,
+		Size:        deref64(props.ContentLength),
+		ContentType: deref(props.ContentType),
+		Version:     deref(props.VersionID),
+		MD5:         hex.EncodeToString(props.ContentMD5),
+		Metadata:    convertMetadata(props.Metadata),
+	}
+	if props.CreationTime != nil {
+		a.Uploaded = *props.CreationTime
+	}
+	if props.LastModified != nil {
+		a.LastModified = *props.LastModified
+	}
+	return a, nil
+}
+
+// Size returns the size of the endpoint's blob.
+func (e *Endpoint) Size(ctx context.Context) (int64, error) {
+	props, err := e.client.NewBlobClient(e.path).GetProperties(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	return deref64(props.ContentLength), nil
+}
+
+// Checksums reports that Stat returns MD5 checksums.  Blobs uploaded in
+// blocks, as this backend uploads them, have one only if the uploader set
+// it.
+func (e *Endpoint) Checksums() []string { return []string{"md5"} }
+
+func convertItem(item *container.BlobItem) *backend.Attrs {
+	a := &backend.Attrs{
+		Name:     deref(item.Name),
+		Version:  deref(item.VersionID),
+		Hider:    item.Deleted != nil && *item.Deleted,
+		Latest:   item.IsCurrentVersion != nil && *item.IsCurrentVersion,
+		Metadata: convertMetadata(item.Metadata),
+	}
+	if p := item.Properties; p != nil {
+		a.Size = deref64(p.ContentLength)
+		a.ContentType = deref(p.ContentType)
+		a.MD5 = hex.EncodeToString(p.ContentMD5)
+		if p.CreationTime != nil {
+			a.Uploaded = *p.CreationTime
+		}
+		if p.LastModified != nil {
+			a.LastModified = *p.LastModified
+		}
+	}
+	return a
+}
+
+func convertMetadata(m map[string]*string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	md := make(map[string]string, len(m))
+	for k, v := range m {
+		md[k] = deref(v)
+	}
+	return md
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func deref64(n *int64) int64 {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
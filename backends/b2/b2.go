@@ -15,54 +15,45 @@
 package b2
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html/template"
 	"io"
 	"math"
-	"net/http"
 	"net/url"
 	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
-	"time"
+	"sync"
+	"sync/atomic"
 
 	"github.com/kurin/blazer/b2"
-	"github.com/kurin/cloudpipe/internal/b2assets"
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/status"
 )
 
-var (
-	statusFuncMap = template.FuncMap{
-		"inc": func(i int) int { return i + 1 },
-		"pRange": func(i int) string {
-			f := float64(i)
-			min := int(math.Pow(2, f)) - 1
-			max := min + int(math.Pow(2, f))
-			return fmt.Sprintf("%v - %v", time.Duration(min)*time.Millisecond, time.Duration(max)*time.Millisecond)
-		},
-		"lookUp": func(m map[string]int, s string) int {
-			return m[s]
-		},
-	}
-	statusTemplate = template.Must(template.New("status").Funcs(statusFuncMap).Parse(string(b2assets.MustAsset("data/status.html"))))
-)
+func init() {
+	backend.Register("b2", open)
+}
 
 type Endpoint struct {
 	Connections int
 	Resume      bool
 
-	Hide      bool
-	Hidden    bool
-	Recursive bool
-	Bucket    bool
+	Hide        bool
+	Hidden      bool
+	Recursive   bool
+	AllVersions bool
+	Threads     int
+	Detail      bool
 
 	attrs  *b2.Attrs
 	b2     *b2.Client
 	bucket string
 	path   string
+	stats  backend.RemoveStats
 }
 
 type Config struct {
@@ -100,11 +91,44 @@ func loadAuth() (*Config, error) {
 	return c, nil
 }
 
-type status struct {
-	Writers    map[string]*b2.WriterStatus
-	Readers    map[string]*b2.ReaderStatus
-	MethodHist map[string][]int
-	Calls      map[string]int
+var (
+	clientsMu sync.Mutex
+	clients   []*b2.Client
+)
+
+// addClient includes the client's call statistics in the status page.
+func addClient(c *b2.Client) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if clients == nil {
+		status.AddSource(addStatus)
+	}
+	clients = append(clients, c)
+}
+
+// addStatus adds the call counts and latencies of every client to s.
+func addStatus(s *status.Snapshot) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	for _, client := range clients {
+		st := client.Status()
+		for method, n := range st.MethodInfo.CountByMethod() {
+			s.Calls[method] += n
+		}
+		for method, hist := range st.MethodInfo.HistogramByMethod() {
+			sum := s.MethodHist[method]
+			for len(sum) < len(hist) {
+				sum = append(sum, 0)
+			}
+			for i, n := range hist {
+				sum[i] += n
+				// Blazer doesn't total latencies, so estimate from the
+				// middle of each bucket.
+				s.Latency[method] += float64(n) * (1.5*math.Exp2(float64(i)) - 1) / 1000
+			}
+			s.MethodHist[method] = sum
+		}
+	}
 }
 
 func New(ctx context.Context, uri *url.URL) (*Endpoint, error) {
@@ -117,18 +141,7 @@ func New(ctx context.Context, uri *url.URL) (*Endpoint, error) {
 		return nil, err
 	}
 
-	hf := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		st := client.Status()
-		var s status
-		s.Readers = st.Readers
-		s.Writers = st.Writers
-		s.Calls = st.MethodInfo.CountByMethod()
-		s.MethodHist = st.MethodInfo.HistogramByMethod()
-		statusTemplate.Execute(rw, s)
-	})
-
-	http.Handle("/progress", hf)
-	go func() { fmt.Println(http.ListenAndServe("0.0.0.0:8822", nil)) }()
+	addClient(client)
 
 	return &Endpoint{
 		b2:     client,
@@ -137,6 +150,33 @@ func New(ctx context.Context, uri *url.URL) (*Endpoint, error) {
 	}, nil
 }
 
+func open(ctx context.Context, uri *url.URL, opts *backend.Options) (backend.Endpoint, error) {
+	ep, err := New(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	ep.Connections = opts.Connections
+	ep.Resume = opts.Resume
+	ep.Hide = opts.Hide
+	ep.Hidden = opts.Hidden
+	ep.Recursive = opts.Recursive
+	ep.AllVersions = opts.AllVersions
+	ep.Threads = opts.Threads
+	ep.Detail = opts.Detail
+	return ep, nil
+}
+
+// Name returns the endpoint's object name.
+func (e *Endpoint) Name() string { return e.path }
+
+// Object returns an endpoint for another object in the same bucket.
+func (e *Endpoint) Object(name string) backend.Endpoint {
+	ep := *e
+	ep.path = name
+	ep.stats = backend.RemoveStats{}
+	return &ep
+}
+
 func (e *Endpoint) Writer(ctx context.Context) (io.WriteCloser, error) {
 	bucket, err := e.b2.NewBucket(ctx, e.bucket, nil)
 	if err != nil {
@@ -150,7 +190,7 @@ func (e *Endpoint) Writer(ctx context.Context) (io.WriteCloser, error) {
 	if e.attrs != nil {
 		w = w.WithAttrs(e.attrs)
 	}
-	return w, nil
+	return status.TrackWriter(e.uri(), w, e.Chunks), nil
 }
 
 func (e *Endpoint) Reader(ctx context.Context) (io.ReadCloser, error) {
@@ -160,9 +200,23 @@ func (e *Endpoint) Reader(ctx context.Context) (io.ReadCloser, error) {
 	}
 	r := bucket.Object(e.path).NewReader(ctx)
 	r.ConcurrentDownloads = e.Connections
-	return r, nil
+	return status.TrackReader(e.uri(), r, e.Chunks), nil
+}
+
+// RangeReader reads length bytes of the object starting at offset, or to the
+// end if length is negative.
+func (e *Endpoint) RangeReader(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	bucket, err := e.b2.Bucket(ctx, e.bucket)
+	if err != nil {
+		return nil, err
+	}
+	r := bucket.Object(e.path).NewRangeReader(ctx, offset, length)
+	r.ConcurrentDownloads = e.Connections
+	return status.TrackReader(e.uri(), r, e.Chunks), nil
 }
 
+func (e *Endpoint) uri() string { return "b2://" + e.bucket + "/" + e.path }
+
 func (e *Endpoint) Label(l string) {
 	labels := strings.Split(l, ",")
 	m := make(map[string]string)
@@ -177,17 +231,17 @@ func (e *Endpoint) Label(l string) {
 	e.attrs = &b2.Attrs{Info: m}
 }
 
-func (e *Endpoint) List(ctx context.Context) (chan string, chan error, error) {
+func (e *Endpoint) List(ctx context.Context) (chan *backend.Attrs, chan error, error) {
 	bucket, err := e.b2.Bucket(ctx, e.bucket)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	sch := make(chan string)
-	ech := make(chan error)
+	ach := make(chan *backend.Attrs)
+	ech := make(chan error, 1)
 
 	go func() {
-		defer close(sch)
+		defer close(ach)
 		defer close(ech)
 
 		lister := bucket.ListCurrentObjects
@@ -196,6 +250,11 @@ func (e *Endpoint) List(ctx context.Context) (chan string, chan error, error) {
 		}
 
 		c := &b2.Cursor{Prefix: e.path, Delimiter: "/"}
+		if e.Recursive {
+			c.Delimiter = ""
+		}
+		// B2 lists the versions of each name newest first.
+		var last string
 		for {
 			list, ncur, err := lister(ctx, 100, c)
 			if err != nil && err != io.EOF {
@@ -204,7 +263,16 @@ func (e *Endpoint) List(ctx context.Context) (chan string, chan error, error) {
 			}
 			c = ncur
 			for _, obj := range list {
-				sch <- obj.Name()
+				a, err := e.listAttrs(ctx, obj)
+				if err != nil {
+					ech <- err
+					return
+				}
+				if e.Hidden && !a.Prefix {
+					a.Latest = a.Name != last
+					last = a.Name
+				}
+				ach <- a
 			}
 			if err == io.EOF {
 				return
@@ -212,111 +280,230 @@ func (e *Endpoint) List(ctx context.Context) (chan string, chan error, error) {
 		}
 	}()
 
-	return sch, ech, nil
+	return ach, ech, nil
+}
+
+// listAttrs returns the attributes of a listed object.  Unless Detail is set,
+// only the name and version are filled in, to save a request per object.
+func (e *Endpoint) listAttrs(ctx context.Context, obj *b2.Object) (*backend.Attrs, error) {
+	if !e.Recursive && strings.HasSuffix(obj.Name(), "/") {
+		return &backend.Attrs{Name: obj.Name(), Prefix: true}, nil
+	}
+	if !e.Detail {
+		return &backend.Attrs{Name: obj.Name(), Version: obj.ID()}, nil
+	}
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a := convertAttrs(attrs)
+	a.Version = obj.ID()
+	return a, nil
 }
 
+// Remove deletes or hides the endpoint's object.  If Recursive is set, every
+// object beneath the endpoint's path is removed, using Threads workers.  If
+// AllVersions is set, every version of each object is deleted, not just the
+// most recent.  Failures on individual objects don't stop a recursive
+// removal; they are counted and reported when it completes.
 func (e *Endpoint) Remove(ctx context.Context) error {
+	e.stats = backend.RemoveStats{}
 	bucket, err := e.b2.Bucket(ctx, e.bucket)
 	if err != nil {
 		return err
 	}
-	if !e.Recursive {
-		if e.Bucket {
-			return bucket.Delete(ctx)
-		}
+	// Hiding applies to a name rather than a version, so there's no sense
+	// in hiding every version.
+	allVersions := e.AllVersions && !e.Hide
+	if !e.Recursive && !allVersions {
+		return e.remove(ctx, bucket.Object(e.path))
+	}
 
-		obj := bucket.Object(e.path)
-		if e.Hide {
-			return obj.Hide(ctx)
-		}
-		return obj.Delete(ctx)
+	threads := e.Threads
+	if threads < 1 {
+		threads = 1
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		first error
+	)
+	objs := make(chan *b2.Object)
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range objs {
+				if err := e.remove(ctx, obj); err != nil {
+					mu.Lock()
+					if first == nil {
+						first = fmt.Errorf("%s: %v", obj.Name(), err)
+					}
+					mu.Unlock()
+				}
+			}
+		}()
 	}
 
 	lister := bucket.ListCurrentObjects
-	if e.Hidden {
+	if e.Hidden || allVersions {
 		lister = bucket.ListObjects
 	}
 
 	c := &b2.Cursor{Prefix: e.path}
-	for {
-		list, ncur, err := lister(ctx, 100, c)
-		if err != nil && err != io.EOF {
-			return err
-		}
-		c = ncur
-		for _, obj := range list {
-			op := obj.Delete
-			if e.Hide {
-				op = obj.Hide
-			}
-			if err := op(ctx); err != nil {
+	err = func() error {
+		defer close(objs)
+		for {
+			list, ncur, err := lister(ctx, 1000, c)
+			if err != nil && err != io.EOF {
 				return err
 			}
+			c = ncur
+			for _, obj := range list {
+				if !e.Recursive && obj.Name() != e.path {
+					// Versions of a single object are listed first, so
+					// everything after them can be skipped.
+					return nil
+				}
+				select {
+				case objs <- obj:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
 		}
-		if err == io.EOF {
-			break
-		}
+	}()
+	wg.Wait()
+	if err != nil {
+		return err
 	}
 
-	if e.Bucket {
-		return bucket.Delete(ctx)
+	if failed := atomic.LoadInt64(&e.stats.Failed); failed > 0 {
+		return fmt.Errorf("%d objects could not be removed; first error: %v", failed, first)
 	}
-
 	return nil
 }
 
-func fsize(s int64) string {
-	sfxs := "BkMGT"
-	f := float64(s)
-	for i := 0; i < 5; i++ {
-		if f < 1024 {
-			return fmt.Sprintf("%.2f%c", f, sfxs[i])
-		}
-		f /= 1024
+// MakeBucket creates the endpoint's bucket, which is private unless
+// attrs.Public is set.  It fails if the bucket already exists.
+func (e *Endpoint) MakeBucket(ctx context.Context, attrs *backend.BucketAttrs) error {
+	if _, err := e.b2.Bucket(ctx, e.bucket); err == nil {
+		return errors.New("bucket already exists")
+	} else if !b2.IsNotExist(err) {
+		return err
+	}
+	typ := b2.BucketType(b2.Private)
+	if attrs.Public {
+		typ = b2.Public
 	}
-	return fmt.Sprintf("%dB", s)
+	_, err := e.b2.NewBucket(ctx, e.bucket, &b2.BucketAttrs{Type: typ})
+	return err
 }
 
-func (e *Endpoint) Stat(ctx context.Context) (string, error) {
+// RemoveBucket deletes the endpoint's bucket.  B2 refuses to delete a bucket
+// that holds any file versions.
+func (e *Endpoint) RemoveBucket(ctx context.Context) error {
 	bucket, err := e.b2.Bucket(ctx, e.bucket)
 	if err != nil {
-		return "", err
+		return err
 	}
-	attrs, err := bucket.Object(e.path).Attrs(ctx)
-	if err != nil {
-		return "", err
+	return bucket.Delete(ctx)
+}
+
+func (e *Endpoint) remove(ctx context.Context, obj *b2.Object) error {
+	if e.Hide {
+		if err := obj.Hide(ctx); err != nil {
+			atomic.AddInt64(&e.stats.Failed, 1)
+			return err
+		}
+		atomic.AddInt64(&e.stats.Hidden, 1)
+		return nil
 	}
-	kv := map[string]string{
-		"Name":         attrs.Name,
-		"Size":         fsize(attrs.Size),
-		"Content-Type": attrs.ContentType,
-		"Uploaded":     attrs.UploadTimestamp.Format(time.RubyDate),
+	if err := obj.Delete(ctx); err != nil {
+		atomic.AddInt64(&e.stats.Failed, 1)
+		return err
 	}
-	order := []string{"Name", "Size", "Content-Type", "Uploaded"}
-	if !attrs.LastModified.IsZero() {
-		kv["Last Modified"] = attrs.LastModified.Format(time.RubyDate)
-		order = append(order, "Last Modified")
+	atomic.AddInt64(&e.stats.Deleted, 1)
+	return nil
+}
+
+// RemoveStats reports the objects affected by the last call to Remove.
+func (e *Endpoint) RemoveStats() backend.RemoveStats {
+	return backend.RemoveStats{
+		Deleted: atomic.LoadInt64(&e.stats.Deleted),
+		Hidden:  atomic.LoadInt64(&e.stats.Hidden),
+		Failed:  atomic.LoadInt64(&e.stats.Failed),
 	}
-	if len(attrs.SHA1) == 20 {
-		kv["SHA1"] = attrs.SHA1
-		order = append(order, "SHA1")
+}
+
+func (e *Endpoint) Stat(ctx context.Context) (*backend.Attrs, error) {
+	bucket, err := e.b2.Bucket(ctx, e.bucket)
+	if err != nil {
+		return nil, err
 	}
-	for key, val := range attrs.Info {
-		kv[key] = val
-		order = append(order, key)
+	obj := bucket.Object(e.path)
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return nil, err
 	}
+	a := convertAttrs(attrs)
+	a.Version = obj.ID()
+	return a, nil
+}
 
-	var max int
+// Size returns the size of the endpoint's object.
+func (e *Endpoint) Size(ctx context.Context) (int64, error) {
+	bucket, err := e.b2.Bucket(ctx, e.bucket)
+	if err != nil {
+		return 0, err
+	}
+	attrs, err := bucket.Object(e.path).Attrs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return attrs.Size, nil
+}
 
-	for key := range kv {
-		if len(key) > max {
-			max = len(key)
-		}
+// Chunks returns the progress of every chunk of the endpoint's object being
+// uploaded or downloaded.  The client is shared by every endpoint, and reports
+// the objects it is transferring by bucket and name.
+func (e *Endpoint) Chunks() []float64 {
+	st := e.b2.Status()
+	key := e.bucket + "/" + e.path
+	var chunks []float64
+	if w, ok := st.Writers[key]; ok {
+		chunks = append(chunks, w.Progress...)
+	}
+	if r, ok := st.Readers[key]; ok {
+		chunks = append(chunks, r.Progress...)
 	}
+	return chunks
+}
 
-	buf := &bytes.Buffer{}
-	for _, key := range order {
-		fmt.Fprintf(buf, "%*s: %s\n", max, key, kv[key])
+func convertAttrs(attrs *b2.Attrs) *backend.Attrs {
+	a := &backend.Attrs{
+		Name:         attrs.Name,
+		Size:         attrs.Size,
+		ContentType:  attrs.ContentType,
+		Uploaded:     attrs.UploadTimestamp,
+		LastModified: attrs.LastModified,
+		Metadata:     attrs.Info,
+		Hider:        attrs.Status == b2.Hider,
+	}
+	// Large files have no SHA1 of their own, and B2 reports "none", but the
+	// uploader may have recorded one in the file info.
+	if len(attrs.SHA1) == 40 {
+		a.SHA1 = attrs.SHA1
+	} else if sha1, ok := attrs.Info["large_file_sha1"]; ok {
+		a.SHA1 = sha1
 	}
-	return buf.String(), nil
+	return a
 }
+
+// Checksums reports that Stat returns SHA1 checksums.  Large files have them
+// only if the uploader recorded one.
+func (e *Endpoint) Checksums() []string { return []string{"sha1"} }
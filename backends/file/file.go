@@ -17,12 +17,238 @@ package file
 
 import (
 	"context"
+	"encoding/hex"
 	"io"
+	"mime"
+	"net/url"
 	"os"
+	"path/filepath"
+
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/status"
 )
 
-type Path string
+func init() {
+	open := func(_ context.Context, uri *url.URL, opts *backend.Options) (backend.Endpoint, error) {
+		p := New(uri.Path)
+		p.Recursive = opts.Recursive
+		p.Checksum = opts.Checksum
+		return p, nil
+	}
+	backend.Register("file", open)
+	backend.Register("", open)
+}
+
+// Path is a local file or directory.
+type Path struct {
+	// Recursive causes List to descend into directories, and Remove to
+	// delete them along with their contents.
+	Recursive bool
+
+	// Checksum names the checksum Stat computes: "sha1" (the default),
+	// "md5", or "crc32c".
+	Checksum string
+
+	name string
+}
+
+// New returns a Path for the given file name.  An empty name refers to the
+// current directory.
+func New(name string) *Path {
+	if name == "" {
+		name = "."
+	}
+	return &Path{name: filepath.Clean(name)}
+}
+
+// Name returns the file name.
+func (p *Path) Name() string { return p.name }
+
+// Object returns a Path for another file, with the same options.
+func (p *Path) Object(name string) backend.Endpoint {
+	np := New(name)
+	np.Recursive = p.Recursive
+	np.Checksum = p.Checksum
+	return np
+}
+
+func (p *Path) Label(string) {}
+
+func (p *Path) Reader(context.Context) (io.ReadCloser, error) {
+	f, err := os.Open(p.name)
+	if err != nil {
+		return nil, err
+	}
+	return status.TrackReader(p.name, f, nil), nil
+}
+
+// RangeReader reads length bytes of the file starting at offset, or to the
+// end if length is negative.
+func (p *Path) RangeReader(_ context.Context, offset, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(p.name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	var r io.ReadCloser = f
+	if length >= 0 {
+		r = limitReadCloser{io.LimitReader(f, length), f}
+	}
+	return status.TrackReader(p.name, r, nil), nil
+}
+
+type limitReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Writer creates the file, truncating it if it already exists.  Missing
+// parent directories are created first.
+func (p *Path) Writer(ctx context.Context) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(p.name), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(p.name)
+	if err != nil {
+		return nil, err
+	}
+	return status.TrackWriter(p.name, &writer{ctx: ctx, File: f}, nil), nil
+}
+
+// A writer removes its file when closed after its context is cancelled.
+type writer struct {
+	ctx context.Context
+	*os.File
+}
+
+func (w *writer) Close() error {
+	err := w.File.Close()
+	if cerr := w.ctx.Err(); cerr != nil {
+		os.Remove(w.Name())
+		return cerr
+	}
+	return err
+}
+
+// List sends the path itself if it is a file, or the entries immediately
+// within it if it is a directory.  Directories are sent as prefixes, with a
+// trailing slash, the way remote backends report them.  If Recursive is set,
+// every file beneath the path is sent instead.
+func (p *Path) List(ctx context.Context) (chan *backend.Attrs, chan error, error) {
+	root := p.name
+	if _, err := os.Stat(root); err != nil {
+		return nil, nil, err
+	}
+
+	ach := make(chan *backend.Attrs)
+	ech := make(chan error, 1)
+
+	go func() {
+		defer close(ach)
+		defer close(ech)
+
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if path == root && info.IsDir() {
+				return nil
+			}
+			if info.IsDir() {
+				if p.Recursive {
+					return nil
+				}
+				ach <- &backend.Attrs{Name: path + string(filepath.Separator), Prefix: true}
+				return filepath.SkipDir
+			}
+			ach <- fileAttrs(path, info)
+			return nil
+		})
+		if err != nil {
+			ech <- err
+		}
+	}()
+
+	return ach, ech, nil
+}
+
+func fileAttrs(name string, fi os.FileInfo) *backend.Attrs {
+	return &backend.Attrs{
+		Name:         name,
+		Size:         fi.Size(),
+		ContentType:  mime.TypeByExtension(filepath.Ext(name)),
+		LastModified: fi.ModTime(),
+	}
+}
+
+// Remove deletes the file at the path.  If the path is a directory it is
+// deleted along with its contents when Recursive is set, and only if it is
+// empty otherwise.
+func (p *Path) Remove(context.Context) error {
+	if p.Recursive {
+		if _, err := os.Stat(p.name); err != nil {
+			return err
+		}
+		return os.RemoveAll(p.name)
+	}
+	return os.Remove(p.name)
+}
+
+// Stat returns the attributes of the file at the path, including a checksum
+// of its contents, which requires reading the whole file.
+func (p *Path) Stat(ctx context.Context) (*backend.Attrs, error) {
+	fi, err := os.Stat(p.name)
+	if err != nil {
+		return nil, err
+	}
+	a := fileAttrs(p.name, fi)
+	if fi.IsDir() {
+		return a, nil
+	}
+	sum := p.Checksums()[0]
+	h, err := backend.NewHash(sum)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p.name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	switch sum {
+	case "sha1":
+		a.SHA1 = digest
+	case "md5":
+		a.MD5 = digest
+	case "crc32c":
+		a.CRC32C = digest
+	}
+	return a, nil
+}
+
+// Size returns the size of the file.
+func (p *Path) Size(context.Context) (int64, error) {
+	fi, err := os.Stat(p.name)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
 
-func (p Path) Reader(context.Context) (io.ReadCloser, error)  { return os.Open(string(p)) }
-func (p Path) Writer(context.Context) (io.WriteCloser, error) { return os.Create(string(p)) }
-func (p Path) Label(string)                                   {}
+// Checksums reports the checksum that Stat computes.
+func (p *Path) Checksums() []string {
+	if p.Checksum == "" {
+		return []string{"sha1"}
+	}
+	return []string{p.Checksum}
+}
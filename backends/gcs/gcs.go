@@ -17,19 +17,37 @@ package gcs
 import (
 	"context"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/url"
+	"strconv"
 	"strings"
 
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 
 	"cloud.google.com/go/storage"
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/status"
 
 	"golang.org/x/oauth2/google"
 )
 
+func init() {
+	backend.Register("gcs", func(ctx context.Context, uri *url.URL, opts *backend.Options) (backend.Endpoint, error) {
+		ep, err := New(ctx, opts.Auth, uri)
+		if err != nil {
+			return nil, err
+		}
+		ep.Recursive = opts.Recursive
+		return ep, nil
+	})
+}
+
 // Endpoint satisfies the cloudpipe.endpoint interface.
 type Endpoint struct {
 	// TrueNames controls whether object names will be base64-encoded or not.  If
@@ -40,31 +58,275 @@ type Endpoint struct {
 	// false, writes to existing objects will fail.
 	Overwrite bool
 
+	// Recursive causes List and Remove to apply to every object beneath the
+	// endpoint's path.
+	Recursive bool
+
 	client         *storage.Client
+	project        string
 	bucket, object string
 	m              map[string]string
 }
 
+// Name returns the endpoint's object name.
+func (e *Endpoint) Name() string { return e.object }
+
+// Object returns an endpoint for another object in the same bucket.
+func (e *Endpoint) Object(name string) backend.Endpoint {
+	ep := *e
+	ep.object = name
+	return &ep
+}
+
 // Writer returns a writer for the given object name.  If TrueNames is false,
 // the name is encoded with base64, to prevent slashes from causing weirdness
 // with the GCS bucket browser.
 func (e *Endpoint) Writer(ctx context.Context) (io.WriteCloser, error) {
-	bucket := e.client.Bucket(e.bucket)
-	name := e.object
-	if !e.TrueNames {
-		name = base64.StdEncoding.EncodeToString([]byte(name))
-	}
-	obj := bucket.Object(name)
+	obj := e.client.Bucket(e.bucket).Object(e.name(e.object))
 	if !e.Overwrite {
 		obj = obj.If(storage.Conditions{DoesNotExist: true})
 	}
 	w := obj.NewWriter(ctx)
 	w.ObjectAttrs.Metadata = e.m
-	return w, nil
+	return status.TrackWriter(e.uri(), w, nil), nil
 }
 
+// Reader reads the endpoint's object, under the name Writer stores it as.
 func (e *Endpoint) Reader(ctx context.Context) (io.ReadCloser, error) {
-	return e.client.Bucket(e.bucket).Object(e.object).NewReader(ctx)
+	done := status.Time("gcs.objects.get")
+	r, err := e.client.Bucket(e.bucket).Object(e.name(e.object)).NewReader(ctx)
+	done()
+	if err != nil {
+		return nil, err
+	}
+	return status.TrackReader(e.uri(), r, nil), nil
+}
+
+// RangeReader reads length bytes of the object starting at offset, or to the
+// end if length is negative.
+func (e *Endpoint) RangeReader(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	done := status.Time("gcs.objects.get")
+	r, err := e.client.Bucket(e.bucket).Object(e.name(e.object)).NewRangeReader(ctx, offset, length)
+	done()
+	if err != nil {
+		return nil, err
+	}
+	return status.TrackReader(e.uri(), r, nil), nil
+}
+
+// CopyFrom copies src, which must also be in GCS, to the endpoint's object
+// within GCS.
+func (e *Endpoint) CopyFrom(ctx context.Context, src backend.Endpoint) error {
+	se, ok := src.(*Endpoint)
+	if !ok {
+		return backend.UnsupportedError{Op: "copying from another backend"}
+	}
+	obj := e.client.Bucket(e.bucket).Object(e.name(e.object))
+	if !e.Overwrite {
+		obj = obj.If(storage.Conditions{DoesNotExist: true})
+	}
+	c := obj.CopierFrom(se.client.Bucket(se.bucket).Object(se.name(se.object)))
+	if e.m != nil {
+		c.ObjectAttrs.Metadata = e.m
+	}
+	defer status.Time("gcs.objects.rewrite")()
+	_, err := c.Run(ctx)
+	return err
+}
+
+func (e *Endpoint) uri() string { return "gcs://" + e.bucket + "/" + e.object }
+
+// attrs fetches the attributes of the endpoint's object.
+func (e *Endpoint) attrs(ctx context.Context) (*storage.ObjectAttrs, error) {
+	defer status.Time("gcs.objects.get")()
+	return e.client.Bucket(e.bucket).Object(e.name(e.object)).Attrs(ctx)
+}
+
+// deleteObject deletes the object stored under the given name.
+func (e *Endpoint) deleteObject(ctx context.Context, stored string) error {
+	defer status.Time("gcs.objects.delete")()
+	return e.client.Bucket(e.bucket).Object(stored).Delete(ctx)
+}
+
+// name returns the name under which the given object is stored in GCS.
+func (e *Endpoint) name(s string) string {
+	if e.TrueNames {
+		return s
+	}
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// walk calls fn for each object beneath the endpoint's path, with the object's
+// decoded name and its attributes.  If delim is not empty, names are rolled up
+// at the first delim following the path, and fn is called once for each such
+// prefix with nil attributes.
+//
+// When TrueNames is false, stored names are base64 encoded and so can't be
+// matched by prefix in GCS; instead the entire bucket is listed and the
+// prefix and delimiter are applied here.  Objects whose names aren't valid
+// base64 are skipped.
+func (e *Endpoint) walk(ctx context.Context, delim string, fn func(string, *storage.ObjectAttrs) error) error {
+	q := &storage.Query{Prefix: e.object, Delimiter: delim}
+	if !e.TrueNames {
+		q = nil
+	}
+	it := e.client.Bucket(e.bucket).Objects(ctx, q)
+
+	seen := make(map[string]bool)
+	for {
+		// Next makes a request only once its buffer is empty.
+		var done func()
+		if it.PageInfo().Remaining() == 0 {
+			done = status.Time("gcs.objects.list")
+		}
+		attrs, err := it.Next()
+		if done != nil {
+			done()
+		}
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if e.TrueNames {
+			if attrs.Prefix != "" {
+				err = fn(attrs.Prefix, nil)
+			} else {
+				err = fn(attrs.Name, attrs)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(attrs.Name)
+		if err != nil {
+			continue
+		}
+		name := string(b)
+		if !strings.HasPrefix(name, e.object) {
+			continue
+		}
+		if delim != "" {
+			if i := strings.Index(name[len(e.object):], delim); i >= 0 {
+				name = name[:len(e.object)+i+len(delim)]
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+				if err := fn(name, nil); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := fn(name, attrs); err != nil {
+			return err
+		}
+	}
+}
+
+// List sends the objects and "directories" immediately beneath the
+// endpoint's path, or if Recursive is set, every object beneath it.
+func (e *Endpoint) List(ctx context.Context) (chan *backend.Attrs, chan error, error) {
+	ach := make(chan *backend.Attrs)
+	ech := make(chan error, 1)
+
+	delim := "/"
+	if e.Recursive {
+		delim = ""
+	}
+
+	go func() {
+		defer close(ach)
+		defer close(ech)
+
+		err := e.walk(ctx, delim, func(name string, attrs *storage.ObjectAttrs) error {
+			if attrs == nil {
+				ach <- &backend.Attrs{Name: name, Prefix: true}
+				return nil
+			}
+			ach <- convertAttrs(name, attrs)
+			return nil
+		})
+		if err != nil {
+			ech <- err
+		}
+	}()
+
+	return ach, ech, nil
+}
+
+// Remove deletes the endpoint's object.  If Recursive is set, every object
+// beneath the endpoint's path is deleted instead.
+func (e *Endpoint) Remove(ctx context.Context) error {
+	if !e.Recursive {
+		return e.deleteObject(ctx, e.name(e.object))
+	}
+	return e.walk(ctx, "", func(_ string, attrs *storage.ObjectAttrs) error {
+		return e.deleteObject(ctx, attrs.Name)
+	})
+}
+
+// MakeBucket creates the endpoint's bucket in attrs.Project, or if that is
+// empty, in the project of the key file.
+func (e *Endpoint) MakeBucket(ctx context.Context, attrs *backend.BucketAttrs) error {
+	project := attrs.Project
+	if project == "" {
+		project = e.project
+	}
+	if project == "" {
+		return errors.New("no project given, and none in the key file")
+	}
+	defer status.Time("gcs.buckets.insert")()
+	return e.client.Bucket(e.bucket).Create(ctx, project, &storage.BucketAttrs{
+		Location:     attrs.Location,
+		StorageClass: attrs.StorageClass,
+	})
+}
+
+// RemoveBucket deletes the endpoint's bucket.  GCS refuses to delete a bucket
+// that isn't empty.
+func (e *Endpoint) RemoveBucket(ctx context.Context) error {
+	defer status.Time("gcs.buckets.delete")()
+	return e.client.Bucket(e.bucket).Delete(ctx)
+}
+
+// Stat returns the attributes of the endpoint's object.
+func (e *Endpoint) Stat(ctx context.Context) (*backend.Attrs, error) {
+	attrs, err := e.attrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return convertAttrs(e.object, attrs), nil
+}
+
+// Size returns the size of the endpoint's object.
+func (e *Endpoint) Size(ctx context.Context) (int64, error) {
+	attrs, err := e.attrs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return attrs.Size, nil
+}
+
+// Checksums reports that Stat returns MD5 and CRC32C checksums.  Composite
+// objects have only CRC32C.
+func (e *Endpoint) Checksums() []string { return []string{"md5", "crc32c"} }
+
+func convertAttrs(name string, attrs *storage.ObjectAttrs) *backend.Attrs {
+	return &backend.Attrs{
+		Name:         name,
+		Size:         attrs.Size,
+		ContentType:  attrs.ContentType,
+		Version:      strconv.FormatInt(attrs.Generation, 10),
+		Uploaded:     attrs.Created,
+		LastModified: attrs.Updated,
+		MD5:          hex.EncodeToString(attrs.MD5),
+		CRC32C:       fmt.Sprintf("%08x", attrs.CRC32C),
+		Metadata:     attrs.Metadata,
+	}
 }
 
 func (e *Endpoint) Label(l string) {
@@ -82,7 +344,7 @@ func (e *Endpoint) Label(l string) {
 // New returns an Endpoint for the given bucket.  Auth should point to the
 // project's private key in JSON format.
 func New(ctx context.Context, auth string, url *url.URL) (*Endpoint, error) {
-	c, err := client(ctx, auth)
+	c, project, err := client(ctx, auth)
 	if err != nil {
 		return nil, err
 	}
@@ -90,23 +352,31 @@ func New(ctx context.Context, auth string, url *url.URL) (*Endpoint, error) {
 	object := url.Path
 	object = strings.TrimPrefix(object, "/")
 	return &Endpoint{
-		client: c,
-		bucket: bucket,
-		object: object,
+		client:  c,
+		project: project,
+		bucket:  bucket,
+		object:  object,
 	}, nil
 }
 
-func client(ctx context.Context, auth string) (*storage.Client, error) {
+// client returns a client authorized by the given JSON key file, and the
+// project the key belongs to.
+func client(ctx context.Context, auth string) (*storage.Client, string, error) {
 	if auth == "" {
-		return nil, fmt.Errorf("no auth credentials supplied")
+		return nil, "", fmt.Errorf("no auth credentials supplied")
 	}
 	jsonKey, err := ioutil.ReadFile(auth)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	conf, err := google.JWTConfigFromJSON(jsonKey, storage.ScopeReadWrite)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	var key struct {
+		ProjectID string `json:"project_id"`
 	}
-	return storage.NewClient(ctx, option.WithTokenSource(conf.TokenSource(ctx)))
+	json.Unmarshal(jsonKey, &key)
+	c, err := storage.NewClient(ctx, option.WithTokenSource(conf.TokenSource(ctx)))
+	return c, key.ProjectID, err
 }
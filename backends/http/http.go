@@ -0,0 +1,459 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package http implements the endpoint interface for http and https URLs.
+// URLs can be read, in parallel ranges if the server allows it, and written
+// with PUT, as to presigned upload URLs.  Credentials in the URL are sent
+// with basic authentication.
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/status"
+)
+
+func init() {
+	backend.Register("http", open)
+	backend.Register("https", open)
+}
+
+// defaultChunkSize is the size of the ranges fetched by parallel downloads
+// when PartSize isn't set.
+const defaultChunkSize = 8 << 20
+
+// Endpoint is a single URL.
+type Endpoint struct {
+	// Connections is the number of ranges to fetch at once.  If it is less
+	// than two, or the server doesn't accept ranges, the URL is fetched
+	// with a single request.
+	Connections int
+
+	// PartSize is the size in bytes of each range fetched.
+	PartSize int64
+
+	// Header is added to every request.
+	Header http.Header
+
+	url    *url.URL
+	client *http.Client
+
+	mu   sync.Mutex
+	prog *chunks
+}
+
+// timer records every request made by a client with the status package.
+type timer struct {
+	rt http.RoundTripper
+}
+
+func (t timer) RoundTrip(req *http.Request) (*http.Response, error) {
+	defer status.Time("http." + strings.ToLower(req.Method))()
+	return t.rt.RoundTrip(req)
+}
+
+var client = &http.Client{Transport: timer{http.DefaultTransport}}
+
+// New returns an Endpoint for the given URL.
+func New(u *url.URL) *Endpoint {
+	return &Endpoint{
+		Header: make(http.Header),
+		url:    u,
+		client: client,
+	}
+}
+
+func open(_ context.Context, uri *url.URL, opts *backend.Options) (backend.Endpoint, error) {
+	ep := New(uri)
+	ep.Connections = opts.Connections
+	ep.PartSize = opts.PartSize
+	for _, h := range opts.Headers {
+		i := strings.Index(h, ":")
+		if i < 1 {
+			return nil, fmt.Errorf("%q: want Name: value", h)
+		}
+		ep.Header.Add(strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:]))
+	}
+	return ep, nil
+}
+
+// uri returns the URL without any password, for reporting.
+func (e *Endpoint) uri() string {
+	if _, ok := e.url.User.Password(); !ok {
+		return e.url.String()
+	}
+	u := *e.url
+	u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	return u.String()
+}
+
+func (e *Endpoint) request(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, e.url.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range e.Header {
+		req.Header[k] = v
+	}
+	return req.WithContext(ctx), nil
+}
+
+// do sends req, and returns an error for any status other than those in ok.
+func (e *Endpoint) do(req *http.Request, ok ...int) (*http.Response, error) {
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	// Include the start of the body, which often explains the error.
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	resp.Body.Close()
+	msg := strings.TrimSpace(string(b))
+	if msg == "" {
+		return nil, fmt.Errorf("%s %s: %s", req.Method, e.uri(), resp.Status)
+	}
+	return nil, fmt.Errorf("%s %s: %s: %s", req.Method, e.uri(), resp.Status, msg)
+}
+
+func (e *Endpoint) head(ctx context.Context) (*http.Response, error) {
+	req, err := e.request(ctx, "HEAD", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.do(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+func (e *Endpoint) Reader(ctx context.Context) (io.ReadCloser, error) {
+	return e.RangeReader(ctx, 0, -1)
+}
+
+// RangeReader reads length bytes starting at offset, or to the end if length
+// is negative.  If Connections is more than one and the server accepts byte
+// ranges, ranges of PartSize bytes are fetched in parallel and reassembled
+// in order.  Ranges are requested with If-Range, so that a change to the
+// object mid-download is an error rather than a corrupt copy.
+func (e *Endpoint) RangeReader(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	var r io.ReadCloser
+	if e.Connections > 1 {
+		// Servers that refuse HEAD get a single GET instead.
+		if resp, err := e.head(ctx); err == nil && resp.Header.Get("Accept-Ranges") == "bytes" && resp.ContentLength >= 0 {
+			end := resp.ContentLength
+			if length >= 0 && offset+length < end {
+				end = offset + length
+			}
+			validator := resp.Header.Get("ETag")
+			if validator == "" || strings.HasPrefix(validator, "W/") {
+				validator = resp.Header.Get("Last-Modified")
+			}
+			if validator != "" {
+				r = e.parallel(ctx, offset, end, validator)
+			}
+		}
+	}
+	if r == nil {
+		var err error
+		r, err = e.get(ctx, offset, length)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return status.TrackReader(e.uri(), r, e.Chunks), nil
+}
+
+// get fetches the range with a single request.
+func (e *Endpoint) get(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	req, err := e.request(ctx, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset == 0 && length < 0 {
+		resp, err := e.do(req, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Body, nil
+	}
+	rng := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		if length == 0 {
+			return ioutil.NopCloser(strings.NewReader("")), nil
+		}
+		rng += strconv.FormatInt(offset+length-1, 10)
+	}
+	req.Header.Set("Range", rng)
+	resp, err := e.do(req, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The offset is at or past the end.
+		resp.Body.Close()
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+	return resp.Body, nil
+}
+
+// chunks tracks the progress of the ranges in flight.
+type chunks struct {
+	mu   sync.Mutex
+	done map[int64]*int64 // by offset; values accessed atomically
+	size map[int64]int64
+}
+
+func (c *chunks) add(off, size int64) *int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := new(int64)
+	c.done[off] = n
+	c.size[off] = size
+	return n
+}
+
+func (c *chunks) remove(off int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.done, off)
+	delete(c.size, off)
+}
+
+// Chunks returns the progress of each range being fetched by the endpoint's
+// most recent parallel download.
+func (e *Endpoint) Chunks() []float64 {
+	e.mu.Lock()
+	c := e.prog
+	e.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var offs []int64
+	for off := range c.done {
+		offs = append(offs, off)
+	}
+	sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+	var fs []float64
+	for _, off := range offs {
+		fs = append(fs, float64(atomic.LoadInt64(c.done[off]))/float64(c.size[off]))
+	}
+	return fs
+}
+
+type result struct {
+	b   []byte
+	err error
+}
+
+// parallel fetches [start, end) in ranges, with up to Connections in flight,
+// and returns a reader that yields them in order.
+func (e *Endpoint) parallel(ctx context.Context, start, end int64, validator string) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	size := e.PartSize
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	prog := &chunks{done: make(map[int64]*int64), size: make(map[int64]int64)}
+	e.mu.Lock()
+	e.prog = prog
+	e.mu.Unlock()
+
+	pr, pw := io.Pipe()
+	pending := make(chan chan result, e.Connections-1)
+	go func() {
+		defer close(pending)
+		for off := start; off < end; off += size {
+			n := size
+			if off+n > end {
+				n = end - off
+			}
+			ch := make(chan result, 1)
+			select {
+			case pending <- ch:
+			case <-ctx.Done():
+				return
+			}
+			go func(off, n int64) {
+				b, err := e.fetch(ctx, off, n, validator, prog)
+				ch <- result{b, err}
+			}(off, n)
+		}
+	}()
+	go func() {
+		defer cancel()
+		for ch := range pending {
+			r := <-ch
+			if r.err != nil {
+				pw.CloseWithError(r.err)
+				return
+			}
+			if _, err := pw.Write(r.b); err != nil {
+				return
+			}
+		}
+		pw.Close()
+	}()
+	return &cancelReader{PipeReader: pr, cancel: cancel}
+}
+
+// fetch reads a single range into memory.
+func (e *Endpoint) fetch(ctx context.Context, off, n int64, validator string, prog *chunks) ([]byte, error) {
+	req, err := e.request(ctx, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+	req.Header.Set("If-Range", validator)
+	resp, err := e.do(req, http.StatusPartialContent, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		// The server sends everything when If-Range doesn't match.
+		return nil, fmt.Errorf("%s: changed during download", e.uri())
+	}
+	done := prog.add(off, n)
+	defer prog.remove(off)
+	b := make([]byte, n)
+	var got int
+	for got < len(b) {
+		m, err := resp.Body.Read(b[got:])
+		got += m
+		atomic.AddInt64(done, int64(m))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if int64(got) != n {
+		return nil, fmt.Errorf("%s: short range at %d: got %d bytes, want %d", e.uri(), off, got, n)
+	}
+	return b, nil
+}
+
+type cancelReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *cancelReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+// Writer uploads to the URL with a single PUT when closed.  Services that
+// accept uploads to presigned URLs require a Content-Length, so the data is
+// first spooled to a temporary file.
+func (e *Endpoint) Writer(ctx context.Context) (io.WriteCloser, error) {
+	f, err := ioutil.TempFile("", "cloudpipe-http")
+	if err != nil {
+		return nil, err
+	}
+	return status.TrackWriter(e.uri(), &writer{ctx: ctx, e: e, f: f}, nil), nil
+}
+
+type writer struct {
+	ctx context.Context
+	e   *Endpoint
+	f   *os.File
+}
+
+func (w *writer) Write(p []byte) (int, error) { return w.f.Write(p) }
+
+func (w *writer) Close() error {
+	defer os.Remove(w.f.Name())
+	defer w.f.Close()
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	size, err := w.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	req, err := w.e.request(w.ctx, "PUT", w.f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	resp, err := w.e.do(req, http.StatusOK, http.StatusCreated, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Stat reports what a HEAD request says about the URL: its size, content
+// type, and modification time, with the ETag as the version.
+func (e *Endpoint) Stat(ctx context.Context) (*backend.Attrs, error) {
+	resp, err := e.head(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a := &backend.Attrs{
+		Name:        e.uri(),
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+		Version:     resp.Header.Get("ETag"),
+	}
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		if t, err := http.ParseTime(lm); err == nil {
+			a.LastModified = t
+		}
+	}
+	if ar := resp.Header.Get("Accept-Ranges"); ar != "" {
+		a.Metadata = map[string]string{"Accept-Ranges": ar}
+	}
+	return a, nil
+}
+
+// Size returns the Content-Length reported by a HEAD request.
+func (e *Endpoint) Size(ctx context.Context) (int64, error) {
+	resp, err := e.head(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("%s: size unknown", e.uri())
+	}
+	return resp.ContentLength, nil
+}
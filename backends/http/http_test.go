@@ -0,0 +1,113 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRangeReader(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := []struct {
+		conns          int
+		offset, length int64
+	}{
+		{conns: 1, offset: 0, length: -1},
+		{conns: 1, offset: 10, length: 20},
+		{conns: 4, offset: 0, length: -1},
+		{conns: 4, offset: 95, length: -1},
+		{conns: 4, offset: 5, length: 333},
+		{conns: 4, offset: 2000, length: -1},
+	}
+	for _, e := range table {
+		ep := New(u)
+		ep.Connections = e.conns
+		ep.PartSize = 64
+		r, err := ep.RangeReader(context.Background(), e.offset, e.length)
+		if err != nil {
+			t.Errorf("RangeReader(%d, %d) with %d connections: %v", e.offset, e.length, e.conns, err)
+			continue
+		}
+		got, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Errorf("RangeReader(%d, %d) with %d connections: %v", e.offset, e.length, e.conns, err)
+			continue
+		}
+		want := data[:0]
+		if e.offset < int64(len(data)) {
+			want = data[e.offset:]
+			if e.length >= 0 && e.length < int64(len(want)) {
+				want = want[:e.length]
+			}
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("RangeReader(%d, %d) with %d connections: got %d bytes, want %d", e.offset, e.length, e.conns, len(got), len(want))
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var got []byte
+	var length int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.Header.Get("X-Test") != "yes" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		length = r.ContentLength
+		got, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ep := New(u)
+	ep.Header.Set("X-Test", "yes")
+	w, err := ep.Writer(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "hello, world")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello, world" || length != 12 {
+		t.Errorf("server got %q with length %d, want %q with length 12", got, length, "hello, world")
+	}
+}
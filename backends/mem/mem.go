@@ -0,0 +1,577 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mem implements the endpoint interface in memory, for tests and dry
+// runs.  URIs have the form mem://bucket/name.  Objects keep every version,
+// and can be hidden, as in B2.  Buckets are created by the first write to
+// them, and everything is lost when the process exits.
+//
+// Faults can be injected into any operation with Inject, so that commands
+// can be tested against errors, latency, and short reads.
+package mem
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/status"
+)
+
+func init() {
+	backend.Register("mem", open)
+}
+
+type version struct {
+	id       string
+	data     []byte
+	ctype    string
+	meta     map[string]string
+	uploaded time.Time
+	hider    bool
+	sums     map[string]string
+}
+
+// A bucket holds the versions of each object, oldest first.
+type bucket map[string][]*version
+
+var (
+	mu      sync.Mutex
+	buckets = make(map[string]bucket)
+	nextID  int
+	faults  []*Fault
+)
+
+// Reset deletes every bucket and clears every fault.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	buckets = make(map[string]bucket)
+	faults = nil
+}
+
+// A Fault is injected into the operations it matches.
+type Fault struct {
+	// Op is the operation to affect: "read", "write", "list", "remove", or
+	// "stat".  An empty Op matches every operation.
+	Op string
+
+	// Name limits the fault to objects, or listings, whose names begin with
+	// it.
+	Name string
+
+	// Delay is added to each matching operation.
+	Delay time.Duration
+
+	// Err is returned by each matching operation.  Writes fail when
+	// closed, after their data has been accepted.
+	Err error
+
+	// ShortRead, if positive, makes matching reads fail with
+	// io.ErrUnexpectedEOF after this many bytes.
+	ShortRead int64
+
+	// Count is the number of operations to affect, after which the fault is
+	// removed.  Zero affects every operation.
+	Count int
+}
+
+// Inject adds a fault, which applies to every endpoint until it is used up
+// or Reset is called.
+func Inject(f *Fault) {
+	mu.Lock()
+	defer mu.Unlock()
+	faults = append(faults, f)
+}
+
+// fault applies the delay of the first fault matching op on name, and
+// returns it, or returns nil if none matches.
+func fault(op, name string) *Fault {
+	mu.Lock()
+	var f *Fault
+	for i, ft := range faults {
+		if (ft.Op == "" || ft.Op == op) && strings.HasPrefix(name, ft.Name) {
+			f = ft
+			if f.Count > 0 {
+				f.Count--
+				if f.Count == 0 {
+					faults = append(faults[:i:i], faults[i+1:]...)
+				}
+			}
+			break
+		}
+	}
+	mu.Unlock()
+	if f != nil && f.Delay > 0 {
+		time.Sleep(f.Delay)
+	}
+	return f
+}
+
+// faultErr returns the error of the first fault matching op on name.
+func faultErr(op, name string) error {
+	if f := fault(op, name); f != nil {
+		return f.Err
+	}
+	return nil
+}
+
+// Endpoint is an object, prefix, or bucket in memory.
+type Endpoint struct {
+	Hide        bool
+	Hidden      bool
+	Recursive   bool
+	AllVersions bool
+	Detail      bool
+
+	bucket string
+	path   string
+	meta   map[string]string
+	stats  backend.RemoveStats
+}
+
+// New returns an Endpoint for the given URI.
+func New(uri *url.URL) *Endpoint {
+	return &Endpoint{
+		bucket: uri.Host,
+		path:   strings.TrimPrefix(uri.Path, "/"),
+	}
+}
+
+func open(_ context.Context, uri *url.URL, opts *backend.Options) (backend.Endpoint, error) {
+	ep := New(uri)
+	if ep.bucket == "" {
+		return nil, fmt.Errorf("%s: no bucket", uri)
+	}
+	ep.Hide = opts.Hide
+	ep.Hidden = opts.Hidden
+	ep.Recursive = opts.Recursive
+	ep.AllVersions = opts.AllVersions
+	ep.Detail = opts.Detail
+	return ep, nil
+}
+
+// Name returns the endpoint's object name.
+func (e *Endpoint) Name() string { return e.path }
+
+// Object returns an endpoint for another object in the same bucket.
+func (e *Endpoint) Object(name string) backend.Endpoint {
+	ep := *e
+	ep.path = name
+	ep.stats = backend.RemoveStats{}
+	return &ep
+}
+
+func (e *Endpoint) uri() string { return "mem://" + e.bucket + "/" + e.path }
+
+func (e *Endpoint) notExist(op string) error {
+	return &os.PathError{Op: op, Path: e.uri(), Err: os.ErrNotExist}
+}
+
+// current returns the newest version of the object, or nil if there is none
+// or it is hidden.  The caller must hold mu.
+func (e *Endpoint) current() *version {
+	vs := buckets[e.bucket][e.path]
+	if len(vs) == 0 || vs[len(vs)-1].hider {
+		return nil
+	}
+	return vs[len(vs)-1]
+}
+
+// Label sets the metadata of objects written to the endpoint.
+func (e *Endpoint) Label(l string) {
+	m := make(map[string]string)
+	for _, label := range strings.Split(l, ",") {
+		i := strings.Index(label, "=")
+		if i < 0 {
+			continue
+		}
+		m[strings.TrimSpace(label[:i])] = strings.TrimSpace(label[i+1:])
+	}
+	e.meta = m
+}
+
+func (e *Endpoint) Reader(ctx context.Context) (io.ReadCloser, error) {
+	return e.RangeReader(ctx, 0, -1)
+}
+
+// RangeReader reads length bytes of the object starting at offset, or to the
+// end if length is negative.
+func (e *Endpoint) RangeReader(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	f := fault("read", e.path)
+	if f != nil && f.Err != nil {
+		return nil, f.Err
+	}
+	mu.Lock()
+	v := e.current()
+	mu.Unlock()
+	if v == nil {
+		return nil, e.notExist("read")
+	}
+	data := v.data
+	if offset < 0 || offset > int64(len(data)) {
+		return nil, fmt.Errorf("%s: offset %d out of range", e.uri(), offset)
+	}
+	data = data[offset:]
+	if length >= 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+	r := &reader{r: bytes.NewReader(data), left: -1}
+	if f != nil && f.ShortRead > 0 {
+		r.left = f.ShortRead
+	}
+	return status.TrackReader(e.uri(), r, nil), nil
+}
+
+type reader struct {
+	r    *bytes.Reader
+	left int64 // bytes until a short read fails, or -1
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.left == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if r.left > 0 && int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.r.Read(p)
+	if r.left > 0 {
+		r.left -= int64(n)
+	}
+	return n, err
+}
+
+func (r *reader) Close() error { return nil }
+
+// Writer buffers what is written, and adds it as the object's newest version
+// when closed, unless ctx has been cancelled.
+func (e *Endpoint) Writer(ctx context.Context) (io.WriteCloser, error) {
+	return status.TrackWriter(e.uri(), &writer{ctx: ctx, e: e}, nil), nil
+}
+
+type writer struct {
+	ctx context.Context
+	e   *Endpoint
+	buf bytes.Buffer
+}
+
+func (w *writer) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *writer) Close() error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	if err := faultErr("write", w.e.path); err != nil {
+		return err
+	}
+	data := w.buf.Bytes()
+	v := &version{
+		data:     data,
+		ctype:    "application/octet-stream",
+		meta:     w.e.meta,
+		uploaded: time.Now(),
+		sums:     make(map[string]string),
+	}
+	for _, name := range w.e.Checksums() {
+		h, err := backend.NewHash(name)
+		if err != nil {
+			return err
+		}
+		h.Write(data)
+		v.sums[name] = hex.EncodeToString(h.Sum(nil))
+	}
+	w.e.add(v)
+	return nil
+}
+
+// CopyFrom copies the current version of src, which must also be in memory,
+// to a new version of this object.
+func (e *Endpoint) CopyFrom(ctx context.Context, src backend.Endpoint) error {
+	se, ok := src.(*Endpoint)
+	if !ok {
+		return backend.UnsupportedError{Op: "copying from another backend"}
+	}
+	if err := faultErr("write", e.path); err != nil {
+		return err
+	}
+	mu.Lock()
+	sv := se.current()
+	mu.Unlock()
+	if sv == nil {
+		return se.notExist("copy")
+	}
+	v := *sv
+	v.uploaded = time.Now()
+	e.add(&v)
+	return nil
+}
+
+// add appends v to the object's versions, creating the bucket if need be.
+func (e *Endpoint) add(v *version) {
+	mu.Lock()
+	defer mu.Unlock()
+	nextID++
+	v.id = strconv.Itoa(nextID)
+	b, ok := buckets[e.bucket]
+	if !ok {
+		b = make(bucket)
+		buckets[e.bucket] = b
+	}
+	b[e.path] = append(b[e.path], v)
+}
+
+func (e *Endpoint) attrs(name string, v *version) *backend.Attrs {
+	a := &backend.Attrs{
+		Name:    name,
+		Size:    int64(len(v.data)),
+		Version: v.id,
+		Hider:   v.hider,
+	}
+	if !e.Detail {
+		return a
+	}
+	a.ContentType = v.ctype
+	a.Uploaded = v.uploaded
+	a.LastModified = v.uploaded
+	a.SHA1 = v.sums["sha1"]
+	a.MD5 = v.sums["md5"]
+	a.CRC32C = v.sums["crc32c"]
+	a.Metadata = v.meta
+	return a
+}
+
+// list returns the objects beneath the endpoint's path, sorted by name.  If
+// hidden is set, every version is returned, newest first; otherwise only
+// current, unhidden versions are.  If delim is set, names are rolled up at
+// the first slash following the path.
+func (e *Endpoint) list(hidden, delim bool) []*backend.Attrs {
+	mu.Lock()
+	defer mu.Unlock()
+	b := buckets[e.bucket]
+	var names []string
+	for name := range b {
+		if strings.HasPrefix(name, e.path) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var as []*backend.Attrs
+	seen := make(map[string]bool)
+	for _, name := range names {
+		vs := b[name]
+		if !hidden && (len(vs) == 0 || vs[len(vs)-1].hider) {
+			continue
+		}
+		if delim {
+			if i := strings.Index(name[len(e.path):], "/"); i >= 0 {
+				pre := name[:len(e.path)+i+1]
+				if !seen[pre] {
+					seen[pre] = true
+					as = append(as, &backend.Attrs{Name: pre, Prefix: true})
+				}
+				continue
+			}
+		}
+		if !hidden {
+			as = append(as, e.attrs(name, vs[len(vs)-1]))
+			continue
+		}
+		for i := len(vs) - 1; i >= 0; i-- {
+			a := e.attrs(name, vs[i])
+			a.Latest = i == len(vs)-1
+			as = append(as, a)
+		}
+	}
+	return as
+}
+
+// List sends the objects and "directories" immediately beneath the
+// endpoint's path, or if Recursive is set, every object beneath it.  If
+// Hidden is set, every version of each object is sent, including hiders.
+func (e *Endpoint) List(ctx context.Context) (chan *backend.Attrs, chan error, error) {
+	if err := faultErr("list", e.path); err != nil {
+		return nil, nil, err
+	}
+	as := e.list(e.Hidden, !e.Recursive)
+
+	ach := make(chan *backend.Attrs)
+	ech := make(chan error, 1)
+
+	go func() {
+		defer close(ach)
+		defer close(ech)
+		for _, a := range as {
+			select {
+			case ach <- a:
+			case <-ctx.Done():
+				ech <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return ach, ech, nil
+}
+
+// Remove deletes or hides the endpoint's object.  Deleting removes only the
+// newest version, unless AllVersions is set.  If Recursive is set, every
+// object beneath the endpoint's path is removed.  Failures on individual
+// objects don't stop a recursive removal; they are counted and reported when
+// it completes.
+func (e *Endpoint) Remove(ctx context.Context) error {
+	e.stats = backend.RemoveStats{}
+	if !e.Recursive {
+		return e.remove()
+	}
+
+	var names []string
+	for _, a := range e.list(e.Hidden || e.AllVersions, false) {
+		if len(names) == 0 || names[len(names)-1] != a.Name {
+			names = append(names, a.Name)
+		}
+	}
+
+	var first error
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ep := e.Object(name).(*Endpoint)
+		err := ep.remove()
+		e.stats.Deleted += ep.stats.Deleted
+		e.stats.Hidden += ep.stats.Hidden
+		e.stats.Failed += ep.stats.Failed
+		if err != nil && first == nil {
+			first = fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	if e.stats.Failed > 0 {
+		return fmt.Errorf("%d objects could not be removed; first error: %v", e.stats.Failed, first)
+	}
+	return nil
+}
+
+// remove removes a single object.
+func (e *Endpoint) remove() error {
+	if err := faultErr("remove", e.path); err != nil {
+		e.stats.Failed++
+		return err
+	}
+	if e.Hide {
+		mu.Lock()
+		v := e.current()
+		mu.Unlock()
+		if v == nil {
+			e.stats.Failed++
+			return e.notExist("hide")
+		}
+		e.add(&version{hider: true, uploaded: time.Now()})
+		e.stats.Hidden++
+		return nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	b := buckets[e.bucket]
+	vs := b[e.path]
+	if len(vs) == 0 {
+		e.stats.Failed++
+		return e.notExist("remove")
+	}
+	n := 1
+	if e.AllVersions {
+		n = len(vs)
+	}
+	vs = vs[:len(vs)-n]
+	if len(vs) == 0 {
+		delete(b, e.path)
+	} else {
+		b[e.path] = vs
+	}
+	e.stats.Deleted += int64(n)
+	return nil
+}
+
+// MakeBucket creates the endpoint's bucket, which must not already exist.
+func (e *Endpoint) MakeBucket(ctx context.Context, attrs *backend.BucketAttrs) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := buckets[e.bucket]; ok {
+		return errors.New("bucket already exists")
+	}
+	buckets[e.bucket] = make(bucket)
+	return nil
+}
+
+// RemoveBucket deletes the endpoint's bucket, which must be empty.
+func (e *Endpoint) RemoveBucket(ctx context.Context) error {
+	if err := faultErr("remove", ""); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	b, ok := buckets[e.bucket]
+	if !ok {
+		return e.notExist("remove")
+	}
+	if len(b) > 0 {
+		return errors.New("bucket not empty")
+	}
+	delete(buckets, e.bucket)
+	return nil
+}
+
+// RemoveStats reports the objects affected by the last call to Remove.
+func (e *Endpoint) RemoveStats() backend.RemoveStats { return e.stats }
+
+// Stat returns the attributes of the object's newest version.
+func (e *Endpoint) Stat(ctx context.Context) (*backend.Attrs, error) {
+	if err := faultErr("stat", e.path); err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	v := e.current()
+	if v == nil {
+		return nil, e.notExist("stat")
+	}
+	d := *e
+	d.Detail = true
+	return d.attrs(e.path, v), nil
+}
+
+// Size returns the size of the object's newest version.
+func (e *Endpoint) Size(ctx context.Context) (int64, error) {
+	a, err := e.Stat(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return a.Size, nil
+}
+
+// Checksums reports that Stat returns every checksum cloudpipe knows.
+func (e *Endpoint) Checksums() []string { return []string{"sha1", "md5", "crc32c"} }
@@ -0,0 +1,185 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mem
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/kurin/cloudpipe/backend"
+)
+
+func endpoint(t *testing.T, uri string, opts *backend.Options) *Endpoint {
+	ep, err := backend.Open(context.Background(), uri, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ep.(*Endpoint)
+}
+
+func put(t *testing.T, uri, data string) {
+	w, err := endpoint(t, uri, nil).Writer(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(ep *Endpoint) (string, error) {
+	r, err := ep.Reader(context.Background())
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	return string(b), err
+}
+
+func list(t *testing.T, uri string, opts *backend.Options) []string {
+	ach, ech, err := endpoint(t, uri, opts).List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for a := range ach {
+		name := a.Name
+		if a.Hider {
+			name += " (hidden)"
+		}
+		names = append(names, name)
+	}
+	if err := <-ech; err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestVersions(t *testing.T) {
+	Reset()
+	ctx := context.Background()
+	put(t, "mem://b/a", "one")
+	put(t, "mem://b/a", "two")
+	put(t, "mem://b/dir/c", "three")
+
+	if got, err := get(endpoint(t, "mem://b/a", nil)); got != "two" || err != nil {
+		t.Errorf("read a: got %q, %v; want %q", got, err, "two")
+	}
+	a, err := endpoint(t, "mem://b/a", nil).Stat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Size != 3 || a.SHA1 != "ad782ecdac770fc6eb9a62e44f90873fb97fb26b" {
+		t.Errorf("stat a: got size %d, sha1 %s", a.Size, a.SHA1)
+	}
+
+	if got, want := list(t, "mem://b/", nil), []string{"a", "dir/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("list: got %q, want %q", got, want)
+	}
+	if got, want := list(t, "mem://b/", &backend.Options{Recursive: true}), []string{"a", "dir/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recursive list: got %q, want %q", got, want)
+	}
+
+	if err := endpoint(t, "mem://b/a", &backend.Options{Hide: true}).Remove(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(endpoint(t, "mem://b/a", nil)); err == nil {
+		t.Error("read of hidden object succeeded")
+	}
+	if got, want := list(t, "mem://b/a", &backend.Options{Hidden: true}), []string{"a (hidden)", "a", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hidden list: got %q, want %q", got, want)
+	}
+
+	// Deleting the hider reveals the version beneath it.
+	if err := endpoint(t, "mem://b/a", nil).Remove(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := get(endpoint(t, "mem://b/a", nil)); got != "two" || err != nil {
+		t.Errorf("read a: got %q, %v; want %q", got, err, "two")
+	}
+
+	ep := endpoint(t, "mem://b", &backend.Options{Recursive: true, AllVersions: true})
+	if err := ep.Remove(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ep.RemoveStats(), (backend.RemoveStats{Deleted: 3}); got != want {
+		t.Errorf("remove stats: got %v, want %v", got, want)
+	}
+	if got := list(t, "mem://b/", &backend.Options{Hidden: true}); got != nil {
+		t.Errorf("list after emptying the bucket: got %q", got)
+	}
+	if err := ep.RemoveBucket(ctx); err != nil {
+		t.Errorf("remove empty bucket: %v", err)
+	}
+	if err := ep.RemoveBucket(ctx); err == nil {
+		t.Error("removing a removed bucket succeeded")
+	}
+}
+
+func TestFaults(t *testing.T) {
+	Reset()
+	ctx := context.Background()
+	put(t, "mem://b/a", "hello, world")
+
+	Inject(&Fault{Op: "read", ShortRead: 5, Count: 1})
+	if got, err := get(endpoint(t, "mem://b/a", nil)); got != "hello" || err != io.ErrUnexpectedEOF {
+		t.Errorf("short read: got %q, %v; want %q, %v", got, err, "hello", io.ErrUnexpectedEOF)
+	}
+	if got, err := get(endpoint(t, "mem://b/a", nil)); got != "hello, world" || err != nil {
+		t.Errorf("read after fault was used up: got %q, %v", got, err)
+	}
+
+	boom := errors.New("boom")
+	Inject(&Fault{Op: "write", Name: "bad", Err: boom})
+	put(t, "mem://b/good", "fine")
+	w, err := endpoint(t, "mem://b/bad", nil).Writer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "data")
+	if err := w.Close(); err != boom {
+		t.Errorf("write: got %v, want %v", err, boom)
+	}
+	if _, err := endpoint(t, "mem://b/bad", nil).Stat(ctx); err == nil {
+		t.Error("failed write created an object")
+	}
+}
+
+func TestRangeReader(t *testing.T) {
+	Reset()
+	put(t, "mem://bucket/a", "abcd")
+	ep := endpoint(t, "mem://bucket/a", nil)
+
+	r, err := ep.RangeReader(context.Background(), 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(r)
+	r.Close()
+	if string(b) != "bc" {
+		t.Errorf("RangeReader(1, 2): got %q, want %q", b, "bc")
+	}
+	for _, offset := range []int64{-1, 5} {
+		if _, err := ep.RangeReader(context.Background(), offset, -1); err == nil {
+			t.Errorf("RangeReader(%d, -1): got no error", offset)
+		}
+	}
+}
@@ -0,0 +1,677 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package s3 implements the endpoint interface for Amazon S3 and services
+// compatible with it, such as MinIO and Ceph RGW.
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/status"
+)
+
+func init() {
+	backend.Register("s3", open)
+}
+
+// Endpoint is an object, prefix, or bucket in S3.
+type Endpoint struct {
+	// Connections is the number of parts of a multipart upload to send at
+	// once.
+	Connections int
+
+	// PartSize is the size in bytes of each part of a multipart upload.
+	// Objects smaller than this are uploaded in a single request.
+	PartSize int64
+
+	Hidden      bool
+	Recursive   bool
+	AllVersions bool
+	Hide        bool
+	Threads     int
+	Detail      bool
+
+	client *s3.Client
+	bucket string
+	path   string
+	m      map[string]string
+	stats  backend.RemoveStats
+}
+
+// Config holds the settings saved by the s3config command.  Any field left
+// empty is taken from the usual AWS environment variables and shared
+// configuration files.
+type Config struct {
+	ID     string `json:"accessKeyId,omitempty"`
+	Key    string `json:"secretAccessKey,omitempty"`
+	Region string `json:"region,omitempty"`
+
+	// Endpoint is the URL of an S3-compatible service, such as
+	// "http://localhost:9000" for a local MinIO.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// PathStyle addresses buckets as part of the path rather than the host
+	// name, which most S3-compatible services require.
+	PathStyle bool `json:"pathStyle,omitempty"`
+}
+
+func configPath() (string, error) {
+	u, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(u.HomeDir, ".cloudpipe_s3"), nil
+}
+
+func Save(c *Config) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(c); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadConfig returns the saved configuration, or an empty one if none has
+// been saved.
+func loadConfig() (*Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// timeCalls records every request the client makes with the status package,
+// including those the uploader makes on its own.
+func timeCalls(stack *middleware.Stack) error {
+	return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("cloudpipeStatus",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			defer status.Time("s3." + awsmiddleware.GetOperationName(ctx))()
+			return next.HandleFinalize(ctx, in)
+		}), middleware.After)
+}
+
+func New(ctx context.Context, uri *url.URL) (*Endpoint, error) {
+	c, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	var opts []func(*config.LoadOptions) error
+	if c.Region != "" {
+		opts = append(opts, config.WithRegion(c.Region))
+	}
+	if c.ID != "" {
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(c.ID, c.Key, "")))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Region == "" {
+		// S3-compatible services rarely care, but requests must be signed
+		// for some region.
+		cfg.Region = "us-east-1"
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if c.Endpoint != "" {
+			o.BaseEndpoint = aws.String(c.Endpoint)
+		}
+		o.UsePathStyle = c.PathStyle
+		o.APIOptions = append(o.APIOptions, timeCalls)
+	})
+
+	return &Endpoint{
+		client: client,
+		bucket: uri.Host,
+		path:   strings.TrimPrefix(uri.Path, "/"),
+	}, nil
+}
+
+func open(ctx context.Context, uri *url.URL, opts *backend.Options) (backend.Endpoint, error) {
+	ep, err := New(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	ep.Connections = opts.Connections
+	ep.PartSize = opts.PartSize
+	ep.Hidden = opts.Hidden
+	ep.Recursive = opts.Recursive
+	ep.AllVersions = opts.AllVersions
+	ep.Hide = opts.Hide
+	ep.Threads = opts.Threads
+	ep.Detail = opts.Detail
+	return ep, nil
+}
+
+// Name returns the endpoint's object name.
+func (e *Endpoint) Name() string { return e.path }
+
+// Object returns an endpoint for another object in the same bucket.
+func (e *Endpoint) Object(name string) backend.Endpoint {
+	ep := *e
+	ep.path = name
+	ep.stats = backend.RemoveStats{}
+	return &ep
+}
+
+func (e *Endpoint) uri() string { return "s3://" + e.bucket + "/" + e.path }
+
+// Writer uploads to the endpoint's object.  Data is buffered a part at a
+// time, and anything larger than PartSize is sent as a multipart upload with
+// up to Connections parts in flight.
+func (e *Endpoint) Writer(ctx context.Context) (io.WriteCloser, error) {
+	up := manager.NewUploader(e.client, func(u *manager.Uploader) {
+		if e.PartSize > 0 {
+			u.PartSize = e.PartSize
+		}
+		if e.Connections > 0 {
+			u.Concurrency = e.Connections
+		}
+	})
+	pr, pw := io.Pipe()
+	w := &writer{ctx: ctx, pw: pw, done: make(chan struct{})}
+	go func() {
+		_, err := up.Upload(ctx, &s3.PutObjectInput{
+			Bucket:   aws.String(e.bucket),
+			Key:      aws.String(e.path),
+			Body:     pr,
+			Metadata: e.m,
+		})
+		// If the upload failed, this makes further writes fail too.
+		pr.CloseWithError(err)
+		w.err = err
+		close(w.done)
+	}()
+	return status.TrackWriter(e.uri(), w, nil), nil
+}
+
+// maxCopySize is the largest object that CopyObject can copy.
+const maxCopySize = 5 << 30
+
+// CopyFrom copies src, which must also be in S3 and no larger than 5GiB, to
+// the endpoint's object within S3.
+func (e *Endpoint) CopyFrom(ctx context.Context, src backend.Endpoint) error {
+	se, ok := src.(*Endpoint)
+	if !ok {
+		return backend.UnsupportedError{Op: "copying from another backend"}
+	}
+	size, err := se.Size(ctx)
+	if err != nil {
+		return err
+	}
+	if size > maxCopySize {
+		return backend.UnsupportedError{Op: "copying objects over 5GiB"}
+	}
+	in := &s3.CopyObjectInput{
+		Bucket:     aws.String(e.bucket),
+		Key:        aws.String(e.path),
+		CopySource: aws.String((&url.URL{Path: se.bucket + "/" + se.path}).EscapedPath()),
+	}
+	if e.m != nil {
+		in.Metadata = e.m
+		in.MetadataDirective = types.MetadataDirectiveReplace
+	}
+	_, err = e.client.CopyObject(ctx, in)
+	return err
+}
+
+type writer struct {
+	ctx  context.Context
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+func (w *writer) Write(p []byte) (int, error) { return w.pw.Write(p) }
+
+// Close finishes the upload, or if the context was cancelled, fails it so
+// that nothing is committed.
+func (w *writer) Close() error {
+	if err := w.ctx.Err(); err != nil {
+		w.pw.CloseWithError(err)
+		<-w.done
+		return err
+	}
+	w.pw.Close()
+	<-w.done
+	return w.err
+}
+
+func (e *Endpoint) Reader(ctx context.Context) (io.ReadCloser, error) {
+	out, err := e.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(e.bucket),
+		Key:    aws.String(e.path),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return status.TrackReader(e.uri(), out.Body, nil), nil
+}
+
+func (e *Endpoint) Label(l string) {
+	labels := strings.Split(l, ",")
+	m := make(map[string]string)
+	for _, label := range labels {
+		i := strings.Index(label, "=")
+		if i < 0 {
+			continue
+		}
+		key, val := label[:i], label[i+1:]
+		m[strings.Trim(key, " ")] = strings.Trim(val, " ")
+	}
+	e.m = m
+}
+
+// List sends the objects and "directories" immediately beneath the
+// endpoint's path, or if Recursive is set, every object beneath it.  If
+// Hidden is set, every version is sent, with delete markers as hiders.
+func (e *Endpoint) List(ctx context.Context) (chan *backend.Attrs, chan error, error) {
+	ach := make(chan *backend.Attrs)
+	ech := make(chan error, 1)
+
+	delim := "/"
+	if e.Recursive {
+		delim = ""
+	}
+
+	go func() {
+		defer close(ach)
+		defer close(ech)
+
+		var err error
+		if e.Hidden {
+			err = e.listVersions(ctx, delim, func(a *backend.Attrs) error {
+				ach <- a
+				return nil
+			})
+		} else {
+			err = e.listCurrent(ctx, delim, ach)
+		}
+		if err != nil {
+			ech <- err
+		}
+	}()
+
+	return ach, ech, nil
+}
+
+func (e *Endpoint) listCurrent(ctx context.Context, delim string, ach chan<- *backend.Attrs) error {
+	in := &s3.ListObjectsV2Input{
+		Bucket: aws.String(e.bucket),
+		Prefix: aws.String(e.path),
+	}
+	if delim != "" {
+		in.Delimiter = aws.String(delim)
+	}
+	p := s3.NewListObjectsV2Paginator(e.client, in)
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, cp := range page.CommonPrefixes {
+			ach <- &backend.Attrs{Name: aws.ToString(cp.Prefix), Prefix: true}
+		}
+		for _, obj := range page.Contents {
+			a := &backend.Attrs{
+				Name:         aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+				MD5:          etagMD5(aws.ToString(obj.ETag)),
+			}
+			if e.Detail {
+				// Content types and metadata are only returned by HEAD.
+				ep := e.Object(a.Name).(*Endpoint)
+				var err error
+				a, err = ep.Stat(ctx)
+				if err != nil {
+					return err
+				}
+			}
+			ach <- a
+		}
+	}
+	return nil
+}
+
+// listVersions calls fn for every version and delete marker beneath the
+// endpoint's path, and for each common prefix if delim is set.
+func (e *Endpoint) listVersions(ctx context.Context, delim string, fn func(*backend.Attrs) error) error {
+	in := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(e.bucket),
+		Prefix: aws.String(e.path),
+	}
+	if delim != "" {
+		in.Delimiter = aws.String(delim)
+	}
+	p := s3.NewListObjectVersionsPaginator(e.client, in)
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, cp := range page.CommonPrefixes {
+			if err := fn(&backend.Attrs{Name: aws.ToString(cp.Prefix), Prefix: true}); err != nil {
+				return err
+			}
+		}
+		// A page lists versions and delete markers separately, each ordered by
+		// key and then newest first; merge them so that every key's history
+		// is listed together, in order.
+		vs, dms := page.Versions, page.DeleteMarkers
+		for len(vs) > 0 || len(dms) > 0 {
+			var a *backend.Attrs
+			if len(dms) == 0 || len(vs) > 0 && before(vs[0], dms[0]) {
+				v := vs[0]
+				vs = vs[1:]
+				a = &backend.Attrs{
+					Name:         aws.ToString(v.Key),
+					Size:         aws.ToInt64(v.Size),
+					Version:      aws.ToString(v.VersionId),
+					Latest:       aws.ToBool(v.IsLatest),
+					LastModified: aws.ToTime(v.LastModified),
+					MD5:          etagMD5(aws.ToString(v.ETag)),
+				}
+			} else {
+				dm := dms[0]
+				dms = dms[1:]
+				a = &backend.Attrs{
+					Name:         aws.ToString(dm.Key),
+					Version:      aws.ToString(dm.VersionId),
+					Latest:       aws.ToBool(dm.IsLatest),
+					LastModified: aws.ToTime(dm.LastModified),
+					Hider:        true,
+				}
+			}
+			if err := fn(a); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// before reports whether version v is listed before delete marker dm: by key,
+// then the latest first, and then newest first.
+func before(v types.ObjectVersion, dm types.DeleteMarkerEntry) bool {
+	if vk, dk := aws.ToString(v.Key), aws.ToString(dm.Key); vk != dk {
+		return vk < dk
+	}
+	if vl, dl := aws.ToBool(v.IsLatest), aws.ToBool(dm.IsLatest); vl != dl {
+		return vl
+	}
+	return !aws.ToTime(v.LastModified).Before(aws.ToTime(dm.LastModified))
+}
+
+// deleteBatch is the most objects a single DeleteObjects request may name.
+const deleteBatch = 1000
+
+// Remove deletes the endpoint's object.  If Recursive is set, every object
+// beneath the endpoint's path is deleted, in batches sent by Threads
+// workers.  If AllVersions is set, every version of each object is deleted,
+// not just the most recent; otherwise, deleting an object in a versioned
+// bucket leaves a delete marker that hides it.  Failures on individual
+// objects don't stop a recursive removal; they are counted and reported when
+// it completes.  Hiding objects isn't supported; delete markers are only left
+// by ordinary deletes in versioned buckets.
+func (e *Endpoint) Remove(ctx context.Context) error {
+	e.stats = backend.RemoveStats{}
+	if e.Hide {
+		return backend.UnsupportedError{Op: "hide"}
+	}
+	if !e.Recursive && !e.AllVersions {
+		_, err := e.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(e.bucket),
+			Key:    aws.String(e.path),
+		})
+		if err != nil {
+			atomic.AddInt64(&e.stats.Failed, 1)
+			return err
+		}
+		atomic.AddInt64(&e.stats.Deleted, 1)
+		return nil
+	}
+
+	threads := e.Threads
+	if threads < 1 {
+		threads = 1
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		first error
+	)
+	batches := make(chan []types.ObjectIdentifier)
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if err := e.deleteObjects(ctx, batch); err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	var batch []types.ObjectIdentifier
+	add := func(a *backend.Attrs) error {
+		if !e.Recursive && a.Name != e.path {
+			return nil
+		}
+		id := types.ObjectIdentifier{Key: aws.String(a.Name)}
+		if a.Version != "" {
+			id.VersionId = aws.String(a.Version)
+		}
+		batch = append(batch, id)
+		if len(batch) < deleteBatch {
+			return nil
+		}
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		batch = nil
+		return nil
+	}
+
+	err := func() error {
+		defer close(batches)
+		var err error
+		if e.AllVersions {
+			err = e.listVersions(ctx, "", add)
+		} else {
+			ach := make(chan *backend.Attrs)
+			go func() {
+				defer close(ach)
+				err = e.listCurrent(ctx, "", ach)
+			}()
+			for a := range ach {
+				if aerr := add(a); aerr != nil {
+					// Drain the listing so that it can finish.
+					for range ach {
+					}
+					return aerr
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			batches <- batch
+		}
+		return nil
+	}()
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+
+	if failed := atomic.LoadInt64(&e.stats.Failed); failed > 0 {
+		return fmt.Errorf("%d objects could not be removed; first error: %v", failed, first)
+	}
+	return nil
+}
+
+// MakeBucket creates the endpoint's bucket in attrs.Location, or if that is
+// empty, in the configured region.
+func (e *Endpoint) MakeBucket(ctx context.Context, attrs *backend.BucketAttrs) error {
+	region := attrs.Location
+	if region == "" {
+		region = e.client.Options().Region
+	}
+	in := &s3.CreateBucketInput{Bucket: aws.String(e.bucket)}
+	// us-east-1 is the default, and may not be named.
+	if region != "us-east-1" {
+		in.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+	_, err := e.client.CreateBucket(ctx, in)
+	return err
+}
+
+// RemoveBucket deletes the endpoint's bucket.  S3 refuses to delete a bucket
+// that isn't empty.
+func (e *Endpoint) RemoveBucket(ctx context.Context) error {
+	_, err := e.client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(e.bucket)})
+	return err
+}
+
+// deleteObjects deletes a batch of objects in one request, and returns the
+// first error.
+func (e *Endpoint) deleteObjects(ctx context.Context, ids []types.ObjectIdentifier) error {
+	out, err := e.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(e.bucket),
+		Delete: &types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+	})
+	if err != nil {
+		atomic.AddInt64(&e.stats.Failed, int64(len(ids)))
+		return err
+	}
+	atomic.AddInt64(&e.stats.Deleted, int64(len(ids)-len(out.Errors)))
+	atomic.AddInt64(&e.stats.Failed, int64(len(out.Errors)))
+	if len(out.Errors) > 0 {
+		oe := out.Errors[0]
+		return fmt.Errorf("%s: %s", aws.ToString(oe.Key), aws.ToString(oe.Message))
+	}
+	return nil
+}
+
+// RemoveStats reports the objects affected by the last call to Remove.
+func (e *Endpoint) RemoveStats() backend.RemoveStats {
+	return backend.RemoveStats{
+		Deleted: atomic.LoadInt64(&e.stats.Deleted),
+		Hidden:  atomic.LoadInt64(&e.stats.Hidden),
+		Failed:  atomic.LoadInt64(&e.stats.Failed),
+	}
+}
+
+func (e *Endpoint) head(ctx context.Context) (*s3.HeadObjectOutput, error) {
+	return e.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(e.bucket),
+		Key:    aws.String(e.path),
+	})
+}
+
+func (e *Endpoint) Stat(ctx context.Context) (*backend.Attrs, error) {
+	out, err := e.head(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a := &backend.Attrs{
+		Name:         e.path,
+		Size:         aws.ToInt64(out.ContentLength),
+		ContentType:  aws.ToString(out.ContentType),
+		Version:      aws.ToString(out.VersionId),
+		LastModified: aws.ToTime(out.LastModified),
+		Metadata:     out.Metadata,
+	}
+	// Objects encrypted with KMS have ETags that aren't their MD5.
+	if out.ServerSideEncryption != types.ServerSideEncryptionAwsKms {
+		a.MD5 = etagMD5(aws.ToString(out.ETag))
+	}
+	return a, nil
+}
+
+// Size returns the size of the endpoint's object.
+func (e *Endpoint) Size(ctx context.Context) (int64, error) {
+	out, err := e.head(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return aws.ToInt64(out.ContentLength), nil
+}
+
+// Checksums reports that Stat returns MD5 checksums.  Objects uploaded in
+// more than one part have none.
+func (e *Endpoint) Checksums() []string { return []string{"md5"} }
+
+// etagMD5 returns the MD5 held in an ETag, or "" if it isn't one.  The ETags
+// of multipart uploads are a hash of the parts' hashes, followed by "-" and
+// the number of parts.
+func etagMD5(etag string) string {
+	etag = strings.Trim(etag, `"`)
+	if len(etag) != 32 || strings.Contains(etag, "-") {
+		return ""
+	}
+	return strings.ToLower(etag)
+}
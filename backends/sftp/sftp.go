@@ -0,0 +1,364 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sftp implements the endpoint interface for files on hosts reachable
+// over SSH.  URIs have the form sftp://user@host:port/path; paths are
+// absolute, unless they begin with /~/, in which case they are relative to
+// the user's home directory.
+package sftp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/status"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func init() {
+	backend.Register("sftp", open)
+}
+
+// Endpoint is a file or directory on a remote host.
+type Endpoint struct {
+	// Connections is the number of requests to keep in flight when reading
+	// or writing a file.
+	Connections int
+
+	// Recursive causes List to descend into directories, and Remove to
+	// delete them along with their contents.
+	Recursive bool
+
+	client *sftp.Client
+	addr   string
+	path   string
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[string]*sftp.Client)
+)
+
+// defaultKeys are the key files tried, in ~/.ssh, when none is given.
+var defaultKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// signers returns the keys to authenticate with: those held by the SSH agent,
+// followed by the key in the given file, or if there is none, by any of the
+// usual key files that exist and aren't protected by a passphrase.
+func signers(keyFile, home string) ([]ssh.Signer, error) {
+	var ss []ssh.Signer
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			if as, err := agent.NewClient(conn).Signers(); err == nil {
+				ss = append(ss, as...)
+			}
+		}
+	}
+	if keyFile != "" {
+		b, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		s, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", keyFile, err)
+		}
+		return append(ss, s), nil
+	}
+	for _, name := range defaultKeys {
+		b, err := ioutil.ReadFile(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+		if s, err := ssh.ParsePrivateKey(b); err == nil {
+			ss = append(ss, s)
+		}
+	}
+	return ss, nil
+}
+
+// dial returns a client for the given user and address, reusing one from an
+// earlier call if there is one.  Host keys are checked against
+// ~/.ssh/known_hosts; unknown hosts are refused.
+func dial(ctx context.Context, username, addr, keyFile string) (*sftp.Client, error) {
+	key := username + "@" + addr
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if c, ok := clients[key]; ok {
+		return c, nil
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	if username == "" {
+		username = u.Username
+	}
+	hostKeys, err := knownhosts.New(filepath.Join(u.HomeDir, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, err
+	}
+	ss, err := signers(keyFile, u.HomeDir)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &ssh.ClientConfig{
+		User:            username,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(ss...)},
+		HostKeyCallback: hostKeys,
+	}
+
+	done := status.Time("sftp.connect")
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	sc, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	if err != nil {
+		conn.Close()
+		done()
+		return nil, err
+	}
+	c, err := sftp.NewClient(ssh.NewClient(sc, chans, reqs), sftp.UseConcurrentReads(true), sftp.UseConcurrentWrites(true))
+	done()
+	if err != nil {
+		sc.Close()
+		return nil, err
+	}
+	clients[key] = c
+	return c, nil
+}
+
+// New returns an Endpoint for the given URI.  If keyFile is not empty, it
+// names a private key to try after those held by the SSH agent.
+func New(ctx context.Context, uri *url.URL, keyFile string) (*Endpoint, error) {
+	if uri.Host == "" {
+		return nil, fmt.Errorf("%s: no host", uri)
+	}
+	addr := uri.Host
+	if uri.Port() == "" {
+		addr = net.JoinHostPort(uri.Hostname(), "22")
+	}
+	p := uri.Path
+	if p == "" {
+		p = "/"
+	}
+	if p == "/~" || strings.HasPrefix(p, "/~/") {
+		// The server resolves relative paths from the home directory.
+		p = "." + strings.TrimPrefix(p, "/~")
+	}
+	client, err := dial(ctx, uri.User.Username(), addr, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Endpoint{
+		client: client,
+		addr:   addr,
+		path:   path.Clean(p),
+	}, nil
+}
+
+func open(ctx context.Context, uri *url.URL, opts *backend.Options) (backend.Endpoint, error) {
+	ep, err := New(ctx, uri, opts.Auth)
+	if err != nil {
+		return nil, err
+	}
+	ep.Connections = opts.Connections
+	ep.Recursive = opts.Recursive
+	return ep, nil
+}
+
+// Name returns the file name.
+func (e *Endpoint) Name() string { return e.path }
+
+// Object returns an endpoint for another file on the same host.
+func (e *Endpoint) Object(name string) backend.Endpoint {
+	ep := *e
+	ep.path = path.Clean(name)
+	return &ep
+}
+
+func (e *Endpoint) uri() string { return "sftp://" + e.addr + "/" + strings.TrimPrefix(e.path, "/") }
+
+// Reader reads the file with up to Connections requests in flight, which
+// matters a great deal over high-latency links.
+func (e *Endpoint) Reader(ctx context.Context) (io.ReadCloser, error) {
+	f, err := e.client.Open(e.path)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := f.WriteTo(pw)
+		f.Close()
+		pw.CloseWithError(err)
+	}()
+	return status.TrackReader(e.uri(), pr, nil), nil
+}
+
+// Writer creates the file, truncating it if it already exists.  Missing
+// parent directories are created first.  Up to Connections writes are kept in
+// flight.
+func (e *Endpoint) Writer(ctx context.Context) (io.WriteCloser, error) {
+	if err := e.client.MkdirAll(path.Dir(e.path)); err != nil {
+		return nil, err
+	}
+	f, err := e.client.Create(e.path)
+	if err != nil {
+		return nil, err
+	}
+	conns := e.Connections
+	if conns < 1 {
+		conns = 1
+	}
+	pr, pw := io.Pipe()
+	w := &writer{ctx: ctx, e: e, pw: pw, done: make(chan struct{})}
+	go func() {
+		_, err := f.ReadFromWithConcurrency(pr, conns)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		// If the write failed, this makes further writes fail too.
+		pr.CloseWithError(err)
+		w.err = err
+		close(w.done)
+	}()
+	return status.TrackWriter(e.uri(), w, nil), nil
+}
+
+type writer struct {
+	ctx  context.Context
+	e    *Endpoint
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+func (w *writer) Write(p []byte) (int, error) { return w.pw.Write(p) }
+
+// Close finishes the upload, or if the context was cancelled, abandons it and
+// removes the partial file.
+func (w *writer) Close() error {
+	if err := w.ctx.Err(); err != nil {
+		w.pw.CloseWithError(err)
+		<-w.done
+		w.e.client.Remove(w.e.path)
+		return err
+	}
+	w.pw.Close()
+	<-w.done
+	return w.err
+}
+
+// List sends the path itself if it is a file, or the entries immediately
+// within it if it is a directory.  Directories are sent as prefixes, with a
+// trailing slash.  If Recursive is set, every file beneath the path is sent
+// instead.
+func (e *Endpoint) List(ctx context.Context) (chan *backend.Attrs, chan error, error) {
+	root := e.path
+	if _, err := e.client.Stat(root); err != nil {
+		return nil, nil, err
+	}
+
+	ach := make(chan *backend.Attrs)
+	ech := make(chan error, 1)
+
+	go func() {
+		defer close(ach)
+		defer close(ech)
+
+		w := e.client.Walk(root)
+		for w.Step() {
+			if err := w.Err(); err != nil {
+				ech <- err
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				ech <- err
+				return
+			}
+			p, info := w.Path(), w.Stat()
+			if p == root && info.IsDir() {
+				continue
+			}
+			if info.IsDir() {
+				if e.Recursive {
+					continue
+				}
+				ach <- &backend.Attrs{Name: p + "/", Prefix: true}
+				w.SkipDir()
+				continue
+			}
+			ach <- fileAttrs(p, info)
+		}
+	}()
+
+	return ach, ech, nil
+}
+
+func fileAttrs(name string, fi os.FileInfo) *backend.Attrs {
+	return &backend.Attrs{
+		Name:         name,
+		Size:         fi.Size(),
+		ContentType:  mime.TypeByExtension(path.Ext(name)),
+		LastModified: fi.ModTime(),
+	}
+}
+
+// Remove deletes the file at the path.  If the path is a directory it is
+// deleted along with its contents when Recursive is set, and only if it is
+// empty otherwise.
+func (e *Endpoint) Remove(context.Context) error {
+	if e.Recursive {
+		return e.client.RemoveAll(e.path)
+	}
+	return e.client.Remove(e.path)
+}
+
+// Stat returns the attributes of the file at the path.  SFTP has no way to
+// checksum a file without reading all of it, so none is reported.
+func (e *Endpoint) Stat(context.Context) (*backend.Attrs, error) {
+	fi, err := e.client.Stat(e.path)
+	if err != nil {
+		return nil, err
+	}
+	return fileAttrs(e.path, fi), nil
+}
+
+// Size returns the size of the file.
+func (e *Endpoint) Size(context.Context) (int64, error) {
+	fi, err := e.client.Stat(e.path)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
@@ -17,14 +17,32 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 
 	"github.com/google/subcommands"
+	_ "github.com/kurin/cloudpipe/backends/az"
+	_ "github.com/kurin/cloudpipe/backends/b2"
+	_ "github.com/kurin/cloudpipe/backends/file"
+	_ "github.com/kurin/cloudpipe/backends/gcs"
+	_ "github.com/kurin/cloudpipe/backends/http"
+	_ "github.com/kurin/cloudpipe/backends/mem"
+	_ "github.com/kurin/cloudpipe/backends/s3"
+	_ "github.com/kurin/cloudpipe/backends/sftp"
+	"github.com/kurin/cloudpipe/commands/azconfig"
 	"github.com/kurin/cloudpipe/commands/b2config"
+	"github.com/kurin/cloudpipe/commands/cat"
 	"github.com/kurin/cloudpipe/commands/cp"
+	"github.com/kurin/cloudpipe/commands/du"
 	"github.com/kurin/cloudpipe/commands/ls"
+	"github.com/kurin/cloudpipe/commands/mb"
+	"github.com/kurin/cloudpipe/commands/mv"
+	"github.com/kurin/cloudpipe/commands/rb"
 	"github.com/kurin/cloudpipe/commands/rm"
+	"github.com/kurin/cloudpipe/commands/s3config"
 	"github.com/kurin/cloudpipe/commands/stat"
+	"github.com/kurin/cloudpipe/commands/sync"
+	"github.com/kurin/cloudpipe/internal/status"
 )
 
 var (
@@ -32,16 +50,32 @@ var (
 	resume      = flag.Bool("resume", false, "Resume an upload (b2).")
 	connections = flag.Int("connections", 4, "Number of simultaneous connections (b2).")
 	labels      = flag.String("labels", "", "Comma-separated key=value pairs (gcs, b2).")
+	statusAddr  = flag.String("status_addr", "", "Serve transfer status on this address, e.g. localhost:8822, at /progress (HTML, updated live), /progress.json, /progress/events (server-sent events), and /metrics (Prometheus).  Disabled if empty.")
 )
 
 func main() {
 	subcommands.Register(&cp.Cmd{}, "")
+	subcommands.Register(&mv.Cmd{}, "")
 	subcommands.Register(&rm.Cmd{}, "")
 	subcommands.Register(&ls.Cmd{}, "")
 	subcommands.Register(&stat.Cmd{}, "")
+	subcommands.Register(&cat.Cmd{}, "")
+	subcommands.Register(&du.Cmd{}, "")
+	subcommands.Register(&mb.Cmd{}, "")
+	subcommands.Register(&rb.Cmd{}, "")
+	subcommands.Register(&sync.Cmd{}, "")
 	subcommands.Register(&b2config.Cmd{}, "configuration")
+	subcommands.Register(&s3config.Cmd{}, "configuration")
+	subcommands.Register(&azconfig.Cmd{}, "configuration")
 	flag.Parse()
 
+	if *statusAddr != "" {
+		if err := status.Start(*statusAddr); err != nil {
+			fmt.Fprintf(os.Stderr, "status server: %v\n", err)
+			os.Exit(int(subcommands.ExitFailure))
+		}
+	}
+
 	ctx := context.Background()
 
 	os.Exit(int(subcommands.Execute(ctx)))
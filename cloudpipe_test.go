@@ -14,30 +14,476 @@
 
 package main
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
 
-func TestSize(t *testing.T) {
-	table := []struct {
-		s    size
+	"github.com/google/subcommands"
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/backends/mem"
+	"github.com/kurin/cloudpipe/commands/cat"
+	"github.com/kurin/cloudpipe/commands/cp"
+	"github.com/kurin/cloudpipe/commands/du"
+	"github.com/kurin/cloudpipe/commands/mb"
+	"github.com/kurin/cloudpipe/commands/mv"
+	"github.com/kurin/cloudpipe/commands/rb"
+	"github.com/kurin/cloudpipe/commands/rm"
+	"github.com/kurin/cloudpipe/commands/sync"
+)
+
+// run executes cmd with the given arguments.
+func run(cmd subcommands.Command, args ...string) subcommands.ExitStatus {
+	f := flag.NewFlagSet(cmd.Name(), flag.ContinueOnError)
+	cmd.SetFlags(f)
+	if err := f.Parse(args); err != nil {
+		return subcommands.ExitUsageError
+	}
+	return cmd.Execute(context.Background(), f)
+}
+
+// stdout runs cmd like run, and returns what it wrote to stdout.
+func stdout(t *testing.T, cmd subcommands.Command, args ...string) (string, subcommands.ExitStatus) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+	out := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		out <- b
+	}()
+	st := run(cmd, args...)
+	w.Close()
+	return string(<-out), st
+}
+
+func read(t *testing.T, uri string) string {
+	ep, err := backend.Open(context.Background(), uri, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := ep.(backend.Reader).Reader(context.Background())
+	if err != nil {
+		t.Fatalf("%s: %v", uri, err)
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", uri, err)
+	}
+	return string(b)
+}
+
+// exists reports whether uri names an object.
+func exists(t *testing.T, uri string) bool {
+	ep, err := backend.Open(context.Background(), uri, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ep.(backend.Statter).Stat(context.Background())
+	return err == nil
+}
+
+// chdir changes to dir, and returns a func that changes back.
+func chdir(t *testing.T, dir string) func() {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return func() { os.Chdir(wd) }
+}
+
+// object is the data to write under a URI when setting up a test.
+type object struct{ uri, data string }
+
+// setup empties the mem backend, then writes objs in order.
+func setup(t *testing.T, objs ...object) {
+	mem.Reset()
+	for _, o := range objs {
+		write(t, o.uri, o.data)
+	}
+}
+
+func write(t *testing.T, uri, data string) {
+	ep, err := backend.Open(context.Background(), uri, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := ep.(backend.Writer).Writer(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	setup(t)
+	dir, err := ioutil.TempDir("", "cloudpipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	if err := ioutil.WriteFile(src, []byte("hello, world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if st := run(&cp.Cmd{}, "-verify", src, "mem://bucket/dst"); st != subcommands.ExitSuccess {
+		t.Fatalf("cp: got %v, want success", st)
+	}
+	if got := read(t, "mem://bucket/dst"); got != "hello, world" {
+		t.Errorf("cp: got %q, want %q", got, "hello, world")
+	}
+
+	// A short read from the source must fail the copy, and leave nothing
+	// behind.
+	for _, dst := range []string{filepath.Join(dir, "short"), "mem://bucket/short"} {
+		mem.Inject(&mem.Fault{Op: "read", ShortRead: 5, Count: 1})
+		if st := run(&cp.Cmd{}, "mem://bucket/dst", dst); st != subcommands.ExitFailure {
+			t.Errorf("cp to %s with a short read: got %v, want failure", dst, st)
+		}
+		if exists(t, dst) {
+			t.Errorf("cp to %s with a short read left a partial object", dst)
+		}
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	setup(t,
+		object{"mem://src/dir/a", "a"},
+		object{"mem://src/dir/sub/b", "b"},
+		object{"mem://src/directory/c", "c"},
+	)
+
+	if st := run(&cp.Cmd{}, "-r", "-verify", "mem://src/dir", "mem://dst/out"); st != subcommands.ExitSuccess {
+		t.Fatalf("cp -r: got %v, want success", st)
+	}
+	for uri, want := range map[string]string{
+		"mem://dst/out/a":     "a",
+		"mem://dst/out/sub/b": "b",
+	} {
+		if got := read(t, uri); got != want {
+			t.Errorf("%s: got %q, want %q", uri, got, want)
+		}
+	}
+	if exists(t, "mem://dst/out/c") {
+		t.Error("cp -r copied an object from outside the source directory")
+	}
+
+	// A single object is copied into the destination directory.
+	if st := run(&cp.Cmd{}, "-r", "mem://src/dir/a", "mem://dst/single"); st != subcommands.ExitSuccess {
+		t.Errorf("cp -r of one object: got %v, want success", st)
+	}
+	if got := read(t, "mem://dst/single/a"); got != "a" {
+		t.Errorf("cp -r of one object: got %q, want %q", got, "a")
+	}
+	if st := run(&cp.Cmd{}, "-r", "mem://src/missing", "mem://dst/none"); st != subcommands.ExitFailure {
+		t.Errorf("cp -r of nothing: got %v, want failure", st)
+	}
+
+	// One failed write fails the copy, but doesn't stop the others.
+	mem.Inject(&mem.Fault{Op: "write", Name: "again/a", Err: errors.New("boom")})
+	if st := run(&cp.Cmd{}, "-r", "mem://src/dir", "mem://dst/again"); st != subcommands.ExitFailure {
+		t.Errorf("cp -r with a failed write: got %v, want failure", st)
+	}
+	if got := read(t, "mem://dst/again/sub/b"); got != "b" {
+		t.Errorf("cp -r with a failed write: got %q, want %q", got, "b")
+	}
+	if exists(t, "mem://dst/again/a") {
+		t.Error("cp -r with a failed write left the failed object")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	setup(t,
+		object{"mem://bucket/a", "a"},
+		object{"mem://bucket/b", "b"},
+		object{"mem://bucket/c", "c"},
+	)
+
+	mem.Inject(&mem.Fault{Op: "remove", Name: "b", Err: errors.New("boom")})
+	if st := run(&rm.Cmd{}, "-r", "mem://bucket/"); st != subcommands.ExitFailure {
+		t.Errorf("rm -r with a failed removal: got %v, want failure", st)
+	}
+	if got := read(t, "mem://bucket/b"); got != "b" {
+		t.Errorf("object that failed removal: got %q, want %q", got, "b")
+	}
+	if exists(t, "mem://bucket/c") {
+		t.Error("rm -r stopped at the first failure")
+	}
+}
+
+func TestSync(t *testing.T) {
+	setup(t,
+		object{"mem://src/dir/a", "a"},
+		object{"mem://src/dir/b", "b"},
+		object{"mem://src/dir/c", "c"},
+		object{"mem://dst/out/b", "old"},
+		object{"mem://dst/out/c", "c"},
+		object{"mem://dst/out/x", "x"},
+	)
+
+	if st := run(&sync.Cmd{}, "-delete", "-dry-run", "mem://src/dir", "mem://dst/out"); st != subcommands.ExitSuccess {
+		t.Fatalf("sync -dry-run: got %v, want success", st)
+	}
+	if got := read(t, "mem://dst/out/b"); got != "old" {
+		t.Errorf("sync -dry-run changed b: got %q", got)
+	}
+
+	// c is up to date, so writing it would fail the sync.
+	mem.Inject(&mem.Fault{Op: "write", Name: "out/c", Err: errors.New("boom")})
+	if st := run(&sync.Cmd{}, "-delete", "mem://src/dir", "mem://dst/out"); st != subcommands.ExitSuccess {
+		t.Fatalf("sync -delete: got %v, want success", st)
+	}
+	for name, want := range map[string]string{"a": "a", "b": "b", "c": "c"} {
+		if got := read(t, "mem://dst/out/"+name); got != want {
+			t.Errorf("sync: %s: got %q, want %q", name, got, want)
+		}
+	}
+	if exists(t, "mem://dst/out/x") {
+		t.Error("sync -delete left an extra object")
+	}
+
+	// A source that doesn't exist fails the sync, rather than looking empty.
+	if st := run(&sync.Cmd{}, "-delete", filepath.Join(os.TempDir(), "cloudpipe-missing"), "mem://dst/out"); st != subcommands.ExitFailure {
+		t.Errorf("sync -delete from a missing source: got %v, want failure", st)
+	}
+	if !exists(t, "mem://dst/out/a") {
+		t.Error("sync -delete from a missing source deleted the destination")
+	}
+
+	// A short read fails the sync, and leaves nothing behind.
+	mem.Inject(&mem.Fault{Op: "read", Name: "dir/a", ShortRead: 1, Count: 1})
+	if st := run(&sync.Cmd{}, "mem://src/dir", "mem://dst/short"); st != subcommands.ExitFailure {
+		t.Errorf("sync with a short read: got %v, want failure", st)
+	}
+	if exists(t, "mem://dst/short/a") {
+		t.Error("sync with a short read left a partial object")
+	}
+
+	// The current directory is synced like any other.
+	dir, err := ioutil.TempDir("", "cloudpipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "a"), []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer chdir(t, dir)()
+	if st := run(&sync.Cmd{}, "-delete", ".", "mem://dst/out"); st != subcommands.ExitSuccess {
+		t.Fatalf("sync -delete from .: got %v, want success", st)
+	}
+	if got := read(t, "mem://dst/out/a"); got != "local" {
+		t.Errorf("sync from .: got %q, want %q", got, "local")
+	}
+	if exists(t, "mem://dst/out/c") {
+		t.Error("sync -delete from . left an extra object")
+	}
+}
+
+func TestMove(t *testing.T) {
+	setup(t)
+	dir, err := ioutil.TempDir("", "cloudpipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	if err := ioutil.WriteFile(src, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if st := run(&mv.Cmd{}, src, "mem://bucket/dir/a"); st != subcommands.ExitSuccess {
+		t.Fatalf("mv: got %v, want success", st)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("mv left the source: %v", err)
+	}
+	write(t, "mem://bucket/dir/b", "b")
+
+	mem.Inject(&mem.Fault{Op: "write", Name: "moved/b", Err: errors.New("boom")})
+	if st := run(&mv.Cmd{}, "-r", "mem://bucket/dir", "mem://bucket/moved"); st != subcommands.ExitFailure {
+		t.Errorf("mv -r with a failed write: got %v, want failure", st)
+	}
+	if got := read(t, "mem://bucket/moved/a"); got != "data" {
+		t.Errorf("mv -r: got %q, want %q", got, "data")
+	}
+	if got := read(t, "mem://bucket/dir/b"); got != "b" {
+		t.Errorf("object that failed to move: got %q, want %q", got, "b")
+	}
+	if exists(t, "mem://bucket/moved/b") {
+		t.Error("mv -r left the object that failed to move at the destination")
+	}
+	if exists(t, "mem://bucket/dir/a") {
+		t.Error("mv -r left a moved object")
+	}
+
+	// Moving onto the source, or into or out of it, would overwrite it.
+	for _, args := range [][]string{
+		{"mem://bucket/dir/b", "mem://bucket/dir/b"},
+		{"-r", "mem://bucket/dir", "mem://bucket/dir/sub"},
+		{"-r", "mem://bucket/dir/", "mem://bucket/"},
+	} {
+		if st := run(&mv.Cmd{}, args...); st != subcommands.ExitUsageError {
+			t.Errorf("mv %v: got %v, want usage error", args, st)
+		}
+	}
+	if got := read(t, "mem://bucket/dir/b"); got != "b" {
+		t.Errorf("mv onto the source: got %q, want %q", got, "b")
+	}
+
+	// A local tree is removed along with the files in it.
+	tree := filepath.Join(dir, "tree")
+	if err := os.MkdirAll(filepath.Join(tree, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tree, "sub", "c"), []byte("c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if st := run(&mv.Cmd{}, "-r", tree, "mem://bucket/tree"); st != subcommands.ExitSuccess {
+		t.Fatalf("mv -r of a local tree: got %v, want success", st)
+	}
+	if got := read(t, "mem://bucket/tree/sub/c"); got != "c" {
+		t.Errorf("mv -r of a local tree: got %q, want %q", got, "c")
+	}
+	if _, err := os.Stat(tree); !os.IsNotExist(err) {
+		t.Errorf("mv -r left the source directory: %v", err)
+	}
+}
+
+func TestCat(t *testing.T) {
+	setup(t,
+		object{"mem://bucket/logs/b.log", "bbbb"},
+		object{"mem://bucket/logs/a.log", "aaaa"},
+		object{"mem://bucket/logs/c.txt", "cccc"},
+		object{"mem://bucket/logs/old/d.log", "dddd"},
+	)
+
+	for _, c := range []struct {
+		args []string
 		want string
 	}{
-		{
-			s:    1024,
-			want: "1.00kB",
-		},
-		{
-			s:    1024 * 1024,
-			want: "1.00MB",
-		},
-		{
-			s:    42.42 * 1024 * 1024 * 1024,
-			want: "42.42GB",
-		},
-	}
-
-	for _, ent := range table {
-		if ent.s.String() != ent.want {
-			t.Errorf("got %q, want %q", ent.s, ent.want)
+		{[]string{"mem://bucket/logs/a.log", "mem://bucket/logs/c.txt"}, "aaaacccc"},
+		{[]string{"mem://bucket/logs/*.log"}, "aaaabbbb"},
+		{[]string{"mem://bucket/logs/**/*.log"}, "aaaabbbbdddd"},
+		{[]string{"-head", "1", "mem://bucket/logs/*.log"}, "ab"},
+		{[]string{"-tail", "1", "mem://bucket/logs/*.log"}, "ab"},
+		{[]string{"-offset", "1", "-length", "2", "mem://bucket/logs/a.log"}, "aa"},
+	} {
+		got, st := stdout(t, &cat.Cmd{}, c.args...)
+		if st != subcommands.ExitSuccess || got != c.want {
+			t.Errorf("cat %v: got %q, %v; want %q", c.args, got, st, c.want)
 		}
 	}
+	if _, st := stdout(t, &cat.Cmd{}, "mem://bucket/logs/*.gz"); st != subcommands.ExitFailure {
+		t.Errorf("cat with no matches: got %v, want failure", st)
+	}
+
+	dir, err := ioutil.TempDir("", "cloudpipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, data := range map[string]string{"b.log": "bb", "a.log": "aa", "c.txt": "cc"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, st := stdout(t, &cat.Cmd{}, filepath.Join(dir, "*.log")); st != subcommands.ExitSuccess || got != "aabb" {
+		t.Errorf("cat of a local glob: got %q, %v; want %q", got, st, "aabb")
+	}
+	defer chdir(t, dir)()
+	if got, st := stdout(t, &cat.Cmd{}, "*.log"); st != subcommands.ExitSuccess || got != "aabb" {
+		t.Errorf("cat of a relative glob: got %q, %v; want %q", got, st, "aabb")
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	setup(t,
+		object{"mem://bucket/du/a", "aa"},
+		object{"mem://bucket/du/a", "aaaa"},
+		object{"mem://bucket/du/sub/b", "bbb"},
+		object{"mem://bucket/du/c", "c"},
+		object{"mem://bucket/dust", "not counted"},
+	)
+	ep, _ := backend.Open(context.Background(), "mem://bucket/du/c", &backend.Options{Hide: true})
+	if err := ep.(backend.Remover).Remove(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	got, st := stdout(t, &du.Cmd{}, "-hidden", "mem://bucket/du")
+	want := "   OBJECTS         BYTES         OLD     OLD BYTES  PATH\n" +
+		"         1             3           0             0  du/sub/\n" +
+		"         2             7           2             3  mem://bucket/du\n"
+	if st != subcommands.ExitSuccess || got != want {
+		t.Errorf("du -hidden: got %v\n%s\nwant\n%s", st, got, want)
+	}
+
+	// The current directory counts the files beneath it.
+	dir, err := ioutil.TempDir("", "cloudpipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"a": "aa", "sub/b": "bbb"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer chdir(t, dir)()
+	got, st = stdout(t, &du.Cmd{}, ".")
+	want = "   OBJECTS         BYTES  PATH\n" +
+		"         1             3  sub/\n" +
+		"         2             5  .\n"
+	if st != subcommands.ExitSuccess || got != want {
+		t.Errorf("du .: got %v\n%s\nwant\n%s", st, got, want)
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	setup(t)
+	if st := run(&mb.Cmd{}, "mem://bucket"); st != subcommands.ExitSuccess {
+		t.Fatalf("mb: got %v, want success", st)
+	}
+	if st := run(&mb.Cmd{}, "mem://bucket"); st != subcommands.ExitFailure {
+		t.Errorf("mb of an existing bucket: got %v, want failure", st)
+	}
+	write(t, "mem://bucket/a", "a")
+	ep, _ := backend.Open(context.Background(), "mem://bucket/a", &backend.Options{Hide: true})
+	if err := ep.(backend.Remover).Remove(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if st := run(&rm.Cmd{}, "mem://bucket"); st != subcommands.ExitUsageError {
+		t.Errorf("rm of a bucket: got %v, want usage error", st)
+	}
+	// Only a hidden object is left, but that still counts.
+	if st := run(&rb.Cmd{}, "mem://bucket"); st != subcommands.ExitFailure {
+		t.Errorf("rb of a non-empty bucket: got %v, want failure", st)
+	}
+	if st := run(&rb.Cmd{}, "-force", "mem://bucket"); st != subcommands.ExitSuccess {
+		t.Errorf("rb -force: got %v, want success", st)
+	}
+	if st := run(&mb.Cmd{}, "mem://bucket"); st != subcommands.ExitSuccess {
+		t.Errorf("mb after rb: got %v, want success", st)
+	}
 }
@@ -0,0 +1,63 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azconfig
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/google/subcommands"
+	"github.com/kurin/cloudpipe/backends/az"
+)
+
+type Cmd struct {
+	account string
+	acct    az.Account
+}
+
+func (*Cmd) Name() string     { return "azconfig" }
+func (*Cmd) Synopsis() string { return "Set Azure storage account options." }
+
+func (*Cmd) Usage() string {
+	return "azconfig -account name [flags]\n"
+}
+
+func (c *Cmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.account, "account", "", "Storage account name.")
+	f.StringVar(&c.acct.Key, "key", "", "Shared key; if empty, AZURE_STORAGE_KEY is used.")
+	f.StringVar(&c.acct.Endpoint, "endpoint", "", "Blob service URL, e.g. http://127.0.0.1:10000/devstoreaccount1 for Azurite.")
+}
+
+func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if c.account == "" {
+		fmt.Fprintf(os.Stderr, "%s", c.Usage())
+		f.PrintDefaults()
+		return subcommands.ExitUsageError
+	}
+	cfg, err := az.LoadConfig()
+	if err != nil {
+		log.Printf("azconfig: %v", err)
+		return subcommands.ExitFailure
+	}
+	cfg.Accounts[c.account] = &c.acct
+	if err := az.Save(cfg); err != nil {
+		log.Printf("azconfig: %v", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,247 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cat
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/subcommands"
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/glob"
+	"github.com/kurin/cloudpipe/internal/object"
+)
+
+type Cmd struct {
+	auth    string
+	conns   int
+	headers backend.Headers
+
+	offset, length int64
+	head, tail     int64
+}
+
+func (*Cmd) Name() string     { return "cat" }
+func (*Cmd) Synopsis() string { return "Write objects to stdout." }
+
+func (*Cmd) Usage() string {
+	return `cat [flags] path...
+
+Write each object to stdout in turn.  A path whose object name contains * or
+[ is a glob pattern, and is replaced by every matching object, in order; **
+matches any number of directories.  Byte ranges apply to each object.
+`
+}
+
+func (c *Cmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs), or SSH private key (sftp)")
+	f.IntVar(&c.conns, "connections", 4, "number of concurrent connections (b2, s3, az, sftp, http)")
+	f.Var(&c.headers, "header", "add this \"Name: value\" header to requests, e.g. for authorization; may be repeated (http)")
+	f.Int64Var(&c.offset, "offset", 0, "start this many bytes into each object")
+	f.Int64Var(&c.length, "length", -1, "write at most this many bytes of each object; -1 writes to the end")
+	f.Int64Var(&c.head, "head", -1, "write only the first this many bytes of each object")
+	f.Int64Var(&c.tail, "tail", -1, "write only the last this many bytes of each object")
+}
+
+func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() < 1 {
+		fmt.Fprintf(os.Stderr, "%s", c.Usage())
+		f.PrintDefaults()
+		return subcommands.ExitUsageError
+	}
+	if c.offset < 0 || c.length < -1 || c.head < -1 || c.tail < -1 {
+		fmt.Fprintln(os.Stderr, "byte counts must not be negative")
+		return subcommands.ExitUsageError
+	}
+	ranged := c.offset > 0 || c.length >= 0
+	if (c.head >= 0 && (c.tail >= 0 || ranged)) || (c.tail >= 0 && ranged) {
+		fmt.Fprintln(os.Stderr, "-head, -tail, and -offset or -length can't be used together")
+		return subcommands.ExitUsageError
+	}
+	if c.head >= 0 {
+		c.length = c.head
+	}
+
+	status := subcommands.ExitSuccess
+	for _, arg := range f.Args() {
+		eps, err := c.expand(ctx, arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", arg, err)
+			status = subcommands.ExitFailure
+			continue
+		}
+		for _, ep := range eps {
+			if err := c.cat(ctx, os.Stdout, ep); err != nil {
+				name := arg
+				if ns, ok := ep.(backend.Namespace); ok {
+					name = ns.Name()
+				}
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				status = subcommands.ExitFailure
+			}
+		}
+	}
+	return status
+}
+
+func (c *Cmd) open(ctx context.Context, uri string, recurse bool) (backend.Endpoint, error) {
+	return backend.Open(ctx, uri, &backend.Options{
+		Auth:        c.auth,
+		Connections: c.conns,
+		Headers:     c.headers,
+		Recursive:   recurse,
+	})
+}
+
+// expand returns the objects that arg names: itself, or if it is a glob
+// pattern, every object that matches it, sorted by name.
+func (c *Cmd) expand(ctx context.Context, arg string) ([]backend.Endpoint, error) {
+	i := patternIndex(arg)
+	if i < 0 {
+		ep, err := c.open(ctx, arg, false)
+		if err != nil {
+			return nil, err
+		}
+		return []backend.Endpoint{ep}, nil
+	}
+
+	// List from the directory holding the first pattern element, and only
+	// descend into subdirectories if the pattern can match them.
+	dir := arg[:strings.LastIndex(arg[:i], "/")+1]
+	rest := arg[len(dir):]
+	if err := glob.Check(rest); err != nil {
+		return nil, err
+	}
+	ep, err := c.open(ctx, dir, strings.Contains(rest, "/"))
+	if err != nil {
+		return nil, err
+	}
+	ns, ok := ep.(backend.Namespace)
+	if !ok {
+		return nil, backend.UnsupportedError{Op: "glob"}
+	}
+	l, ok := ep.(backend.Lister)
+	if !ok {
+		return nil, backend.UnsupportedError{Op: "list"}
+	}
+	// Local paths lose their trailing slash when cleaned.
+	pattern := object.DirName(ns.Name()) + rest
+	objs, errs, err := l.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for a := range objs {
+		if a.Prefix || a.Hider {
+			continue
+		}
+		if ok, _ := glob.Match(pattern, a.Name); ok {
+			names = append(names, a.Name)
+		}
+	}
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no objects match %s", pattern)
+	}
+	sort.Strings(names)
+	var eps []backend.Endpoint
+	for _, name := range names {
+		eps = append(eps, ns.Object(name))
+	}
+	return eps, nil
+}
+
+// patternIndex returns the index of the first glob metacharacter in the path
+// of arg, or -1 if there is none.  A URI's scheme and host are skipped, so
+// that the brackets of an IPv6 address aren't mistaken for a pattern.
+func patternIndex(arg string) int {
+	start := 0
+	if i := strings.Index(arg, "://"); i >= 0 {
+		start = i + len("://")
+		j := strings.Index(arg[start:], "/")
+		if j < 0 {
+			return -1
+		}
+		start += j
+	}
+	i := strings.IndexAny(arg[start:], "*[")
+	if i < 0 {
+		return -1
+	}
+	return start + i
+}
+
+// cat writes the requested range of ep to w, reading only that range if the
+// backend allows it.
+func (c *Cmd) cat(ctx context.Context, w io.Writer, ep backend.Endpoint) error {
+	offset, length := c.offset, c.length
+	if c.tail >= 0 {
+		sz, ok := ep.(backend.Sizer)
+		if !ok {
+			return backend.UnsupportedError{Op: "tail"}
+		}
+		n, err := sz.Size(ctx)
+		if err != nil {
+			return err
+		}
+		if offset = n - c.tail; offset < 0 {
+			offset = 0
+		}
+	}
+	if length == 0 {
+		return nil
+	}
+
+	if rr, ok := ep.(backend.RangeReader); ok && (offset > 0 || length > 0) {
+		r, err := rr.RangeReader(ctx, offset, length)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		_, err = io.Copy(w, r)
+		return err
+	}
+
+	rd, ok := ep.(backend.Reader)
+	if !ok {
+		return backend.UnsupportedError{Op: "read"}
+	}
+	r, err := rd.Reader(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	// Without ranged reads, skip to the offset and stop after length.
+	if _, err := io.CopyN(ioutil.Discard, r, offset); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	var in io.Reader = r
+	if length > 0 {
+		in = io.LimitReader(r, length)
+	}
+	_, err = io.Copy(w, in)
+	return err
+}
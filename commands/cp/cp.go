@@ -19,20 +19,33 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"net/url"
 	"os"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/subcommands"
-	"github.com/kurin/cloudpipe/backends/b2"
-	"github.com/kurin/cloudpipe/backends/file"
-	"github.com/kurin/cloudpipe/backends/gcs"
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/object"
 )
 
 type Cmd struct {
-	resume bool
-	conns  int
-	auth   string
-	labels string
+	resume  bool
+	conns   int
+	part    int64
+	auth    string
+	labels  string
+	recurse bool
+	threads int
+
+	verify    bool
+	deleteBad bool
+	checksum  string
+
+	progress bool
+	prog     *progress
+
+	offset  int64
+	headers backend.Headers
 }
 
 func (*Cmd) Name() string     { return "cp" }
@@ -44,9 +57,18 @@ func (*Cmd) Usage() string {
 
 func (c *Cmd) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&c.resume, "resume", false, "resume an upload (b2)")
-	f.IntVar(&c.conns, "connections", 4, "number of concurrent connections (b2)")
-	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs, b2)")
+	f.IntVar(&c.conns, "connections", 4, "number of concurrent connections (b2, s3, az, sftp)")
+	f.Int64Var(&c.part, "part_size", 0, "size in bytes of each part of a multipart upload, at least 5MiB for s3; 0 uses the default (s3, az)")
+	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs), or SSH private key (sftp)")
 	f.StringVar(&c.labels, "labels", "", "Comma-separated key=value pairs (gcs, b2).")
+	f.BoolVar(&c.recurse, "r", false, "copy every object under the source path to the destination path")
+	f.IntVar(&c.threads, "threads", 4, "copy this many objects in parallel (with -r)")
+	f.BoolVar(&c.verify, "verify", false, "verify the destination's checksum against the data read from the source")
+	f.BoolVar(&c.deleteBad, "delete_bad", false, "delete destination objects that fail verification (with -verify)")
+	f.StringVar(&c.checksum, "checksum", "sha1", "checksum used to verify local files: sha1, md5, or crc32c (file)")
+	f.BoolVar(&c.progress, "progress", false, "report progress on stderr")
+	f.Int64Var(&c.offset, "offset", 0, "start reading the source this many bytes in, e.g. to resume an interrupted download into - (b2, gcs, file, http)")
+	f.Var(&c.headers, "header", "add this \"Name: value\" header to requests, e.g. for authorization; may be repeated (http)")
 }
 
 func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -59,84 +81,168 @@ func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) su
 	srcArg := f.Args()[0]
 	dstArg := f.Args()[1]
 
-	src, err := c.parseURI(ctx, srcArg)
+	if _, err := backend.NewHash(c.checksum); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return subcommands.ExitUsageError
+	}
+
+	if c.offset < 0 || c.offset > 0 && (c.recurse || c.verify) {
+		fmt.Fprintln(os.Stderr, "-offset must not be negative, and can't be used with -r or -verify")
+		return subcommands.ExitUsageError
+	}
+
+	src, err := c.parseURI(ctx, srcArg, c.recurse)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s: %v\n", srcArg, err)
 		return subcommands.ExitFailure
 	}
 
-	dst, err := c.parseURI(ctx, dstArg)
+	// The destination is never opened recursively, so that removing an
+	// object that fails verification can't remove anything else.
+	dst, err := c.parseURI(ctx, dstArg, false)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s: %v\n", dstArg, err)
 		return subcommands.ExitFailure
 	}
 
 	if c.labels != "" {
-		src.Label(c.labels)
-		dst.Label(c.labels)
+		if l, ok := dst.(backend.Labeler); ok {
+			l.Label(c.labels)
+		}
 	}
 
-	r, err := src.Reader(ctx)
-	if err != nil {
+	if c.progress {
+		total := int64(-1)
+		if sz, ok := src.(backend.Sizer); ok && !c.recurse {
+			if n, err := sz.Size(ctx); err == nil && n >= c.offset {
+				total = n - c.offset
+			}
+		}
+		c.prog = newProgress(os.Stderr, time.Second, total, src, dst)
+		defer c.prog.stop()
+	}
+
+	if c.recurse {
+		return c.copyTree(ctx, srcArg, src, dstArg, dst)
+	}
+
+	if err := c.copyObject(ctx, src, dst); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return subcommands.ExitFailure
 	}
 
-	w, err := dst.Writer(ctx)
+	return subcommands.ExitSuccess
+}
+
+// copyObject copies the contents of src to dst, and if c.verify is set,
+// checks that what the destination reports matches what was read.
+func (c *Cmd) copyObject(ctx context.Context, src, dst backend.Endpoint) error {
+	srcR, ok := src.(backend.Reader)
+	if !ok {
+		return backend.UnsupportedError{Op: "read"}
+	}
+	srcRR, ok := src.(backend.RangeReader)
+	if !ok && c.offset > 0 {
+		return backend.UnsupportedError{Op: "reading from an offset"}
+	}
+	if _, ok := dst.(backend.Writer); !ok {
+		return backend.UnsupportedError{Op: "write"}
+	}
+	var v *object.Verifier
+	if c.verify {
+		var err error
+		if v, err = object.NewVerifier(dst); err != nil {
+			return err
+		}
+	}
+
+	var r io.ReadCloser
+	var err error
+	if c.offset > 0 {
+		r, err = srcRR.RangeReader(ctx, c.offset, -1)
+	} else {
+		r, err = srcR.Reader(ctx)
+	}
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return subcommands.ExitFailure
+		return err
 	}
+	defer r.Close()
 
-	if _, err := io.Copy(w, r); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return subcommands.ExitFailure
+	var in io.Reader = r
+	if v != nil {
+		in = io.TeeReader(in, v)
 	}
-	if err := w.Close(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return subcommands.ExitFailure
+	if c.prog != nil {
+		in = io.TeeReader(in, c.prog)
+	}
+	if err := object.Write(ctx, dst, in); err != nil {
+		return err
 	}
 
-	return subcommands.ExitSuccess
+	if v == nil {
+		return nil
+	}
+	verr := v.Check(ctx)
+	if verr == nil || !c.deleteBad {
+		return verr
+	}
+	return object.RemoveBad(ctx, dst, verr)
 }
 
-type endpoint interface {
-	Writer(ctx context.Context) (io.WriteCloser, error)
-	Reader(ctx context.Context) (io.ReadCloser, error)
-	Label(string)
+// copyTree copies every object beneath src to the same relative name beneath
+// dst, using c.threads workers.  Each object's result is reported as it
+// completes.
+func (c *Cmd) copyTree(ctx context.Context, srcArg string, src backend.Endpoint, dstArg string, dst backend.Endpoint) subcommands.ExitStatus {
+	srcNS, ok := src.(backend.Namespace)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", srcArg, backend.UnsupportedError{Op: "recursive copy"})
+		return subcommands.ExitFailure
+	}
+	dstNS, ok := dst.(backend.Namespace)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", dstArg, backend.UnsupportedError{Op: "recursive copy"})
+		return subcommands.ExitFailure
+	}
+
+	var copied, failed int64
+	err := object.Tree(ctx, srcNS, c.threads, func(name, rel string) {
+		to := object.DirName(dstNS.Name()) + rel
+		if err := c.copyObject(ctx, srcNS.Object(name), dstNS.Object(to)); err != nil {
+			atomic.AddInt64(&failed, 1)
+			fmt.Fprintf(os.Stderr, "%s -> %s: %v\n", name, to, err)
+			return
+		}
+		atomic.AddInt64(&copied, 1)
+		fmt.Printf("%s -> %s\n", name, to)
+	})
+	status := subcommands.ExitSuccess
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", srcArg, err)
+		status = subcommands.ExitFailure
+	}
+	fmt.Fprintf(os.Stderr, "%d copied, %d failed\n", copied, failed)
+	if failed > 0 {
+		status = subcommands.ExitFailure
+	}
+	return status
 }
 
 type std struct{}
 
-func (std) Label(string)                                   {}
 func (std) Writer(context.Context) (io.WriteCloser, error) { return os.Stdout, nil }
 func (std) Reader(context.Context) (io.ReadCloser, error)  { return os.Stdin, nil }
 
-func (c *Cmd) parseURI(ctx context.Context, uri string) (endpoint, error) {
+func (c *Cmd) parseURI(ctx context.Context, uri string, recurse bool) (backend.Endpoint, error) {
 	if uri == "-" {
 		return std{}, nil
 	}
-	url, err := url.Parse(uri)
-	if err != nil {
-		return nil, err
-	}
-	switch url.Scheme {
-	case "gcs":
-		ep, err := gcs.New(ctx, c.auth, url)
-		if err != nil {
-			return nil, err
-		}
-		return ep, nil
-	case "b2":
-		ep, err := b2.New(ctx, url)
-		if err != nil {
-			return nil, err
-		}
-		ep.Resume = c.resume
-		ep.Connections = c.conns
-		return ep, nil
-	case "file", "":
-		return file.Path(url.Path), nil
-	}
-	return nil, fmt.Errorf("%s: unknown scheme", url.Scheme)
+	return backend.Open(ctx, uri, &backend.Options{
+		Auth:        c.auth,
+		Connections: c.conns,
+		PartSize:    c.part,
+		Resume:      c.resume,
+		Recursive:   recurse,
+		Checksum:    c.checksum,
+		Headers:     c.headers,
+	})
 }
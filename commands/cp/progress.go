@@ -0,0 +1,115 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/format"
+)
+
+// A progress counts the bytes written to it, and periodically reports the
+// count, throughput, and if the total is known, the time remaining.
+type progress struct {
+	n      int64 // accessed atomically
+	total  int64
+	start  time.Time
+	out    io.Writer
+	chunks []backend.ChunkReporter
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newProgress starts reporting to out every interval until stop is called.
+// A negative total means that the size isn't known.  Chunk progress is
+// included from every endpoint that can report it.
+func newProgress(out io.Writer, interval time.Duration, total int64, eps ...backend.Endpoint) *progress {
+	p := &progress{
+		total: total,
+		start: time.Now(),
+		out:   out,
+		done:  make(chan struct{}),
+	}
+	for _, ep := range eps {
+		if cr, ok := ep.(backend.ChunkReporter); ok {
+			p.chunks = append(p.chunks, cr)
+		}
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				fmt.Fprintf(p.out, "\r%s\x1b[K", p.line())
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	atomic.AddInt64(&p.n, int64(len(b)))
+	return len(b), nil
+}
+
+func (p *progress) line() string {
+	n := atomic.LoadInt64(&p.n)
+	elapsed := time.Since(p.start)
+	rate := float64(n) / elapsed.Seconds()
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s", format.Size(n))
+	if p.total >= 0 {
+		fmt.Fprintf(buf, " / %s", format.Size(p.total))
+	}
+	fmt.Fprintf(buf, "  %s/s", format.Size(int64(rate)))
+	if p.total >= 0 && rate > 0 && n < p.total {
+		eta := time.Duration(float64(p.total-n) / rate * float64(time.Second))
+		fmt.Fprintf(buf, "  ETA %v", eta.Round(time.Second))
+	}
+	var chunks []float64
+	for _, cr := range p.chunks {
+		chunks = append(chunks, cr.Chunks()...)
+	}
+	if len(chunks) > 0 {
+		fmt.Fprintf(buf, "  chunks")
+		for _, c := range chunks {
+			fmt.Fprintf(buf, " %d%%", int(c*100))
+		}
+	}
+	return buf.String()
+}
+
+// stop ends periodic reporting and prints a summary.
+func (p *progress) stop() {
+	close(p.done)
+	p.wg.Wait()
+	n := atomic.LoadInt64(&p.n)
+	elapsed := time.Since(p.start)
+	rate := float64(n) / elapsed.Seconds()
+	fmt.Fprintf(p.out, "\r%s in %v (%s/s)\x1b[K\n", format.Size(n), elapsed.Round(time.Second), format.Size(int64(rate)))
+}
@@ -0,0 +1,174 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package du
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/subcommands"
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/format"
+	"github.com/kurin/cloudpipe/internal/object"
+)
+
+type Cmd struct {
+	auth   string
+	depth  int
+	hidden bool
+	human  bool
+}
+
+func (*Cmd) Name() string     { return "du" }
+func (*Cmd) Synopsis() string { return "Report the space used beneath a path." }
+
+func (*Cmd) Usage() string {
+	return `du [flags] path
+
+Count the objects and bytes beneath path, in total and for each directory up
+to -depth levels below it.
+`
+}
+
+func (c *Cmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs), or SSH private key (sftp)")
+	f.IntVar(&c.depth, "depth", 1, "report directories up to this many levels below the path; 0 reports only the total")
+	f.BoolVar(&c.hidden, "hidden", false, "also count hidden objects and old versions, separately from current ones (b2, s3)")
+	f.BoolVar(&c.human, "h", false, "print sizes in human-readable form")
+}
+
+// usage counts the objects beneath a directory.
+type usage struct {
+	objects, bytes int64
+
+	// Versions that are hidden or no longer current, with -hidden.
+	old, oldBytes int64
+}
+
+func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "%s", c.Usage())
+		f.PrintDefaults()
+		return subcommands.ExitUsageError
+	}
+	if c.depth < 0 {
+		fmt.Fprintln(os.Stderr, "-depth must not be negative")
+		return subcommands.ExitUsageError
+	}
+
+	pathArg := f.Args()[0]
+
+	ep, err := backend.Open(ctx, pathArg, &backend.Options{
+		Auth:      c.auth,
+		Hidden:    c.hidden,
+		Recursive: true,
+		Detail:    true,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", pathArg, err)
+		return subcommands.ExitFailure
+	}
+	var base string
+	if ns, ok := ep.(backend.Namespace); ok {
+		// Treat the path as a directory, so that b2://bucket/dir doesn't
+		// also count b2://bucket/directory.
+		if base = object.DirName(ns.Name()); base != "" {
+			ep = ns.Object(base)
+		}
+	}
+	l, ok := ep.(backend.Lister)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", pathArg, backend.UnsupportedError{Op: "list"})
+		return subcommands.ExitFailure
+	}
+	objs, errs, err := l.List(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", pathArg, err)
+		return subcommands.ExitFailure
+	}
+
+	total := &usage{}
+	dirs := make(map[string]*usage)
+	for a := range objs {
+		if a.Prefix || !strings.HasPrefix(a.Name, base) {
+			continue
+		}
+		// Only the newest version of a name is current, and hiders are
+		// skipped below.
+		current := !c.hidden || a.Latest
+		if a.Hider {
+			continue
+		}
+		elems := strings.Split(strings.TrimPrefix(a.Name, base), "/")
+		for i := 1; i <= c.depth && i < len(elems); i++ {
+			dir := base + strings.Join(elems[:i], "/") + "/"
+			if dirs[dir] == nil {
+				dirs[dir] = &usage{}
+			}
+			dirs[dir].add(a.Size, current)
+		}
+		total.add(a.Size, current)
+	}
+	if err, ok := <-errs; ok {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", pathArg, err)
+		return subcommands.ExitFailure
+	}
+
+	var names []string
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if c.hidden {
+		fmt.Printf("%10s  %12s  %10s  %12s  %s\n", "OBJECTS", "BYTES", "OLD", "OLD BYTES", "PATH")
+	} else {
+		fmt.Printf("%10s  %12s  %s\n", "OBJECTS", "BYTES", "PATH")
+	}
+	for _, name := range names {
+		c.print(dirs[name], name)
+	}
+	c.print(total, pathArg)
+	return subcommands.ExitSuccess
+}
+
+func (u *usage) add(size int64, current bool) {
+	if current {
+		u.objects++
+		u.bytes += size
+		return
+	}
+	u.old++
+	u.oldBytes += size
+}
+
+func (c *Cmd) print(u *usage, name string) {
+	if c.hidden {
+		fmt.Printf("%10d  %12s  %10d  %12s  %s\n", u.objects, c.size(u.bytes), u.old, c.size(u.oldBytes), name)
+		return
+	}
+	fmt.Printf("%10d  %12s  %s\n", u.objects, c.size(u.bytes), name)
+}
+
+func (c *Cmd) size(s int64) string {
+	if c.human {
+		return format.Size(s)
+	}
+	return strconv.FormatInt(s, 10)
+}
@@ -18,16 +18,27 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"net/url"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/google/subcommands"
-	"github.com/kurin/cloudpipe/backends/b2"
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/format"
+	"github.com/kurin/cloudpipe/internal/glob"
 )
 
 type Cmd struct {
-	auth   string
-	hidden bool
+	auth    string
+	hidden  bool
+	format  string
+	long    bool
+	human   bool
+	recurse bool
+	filter  glob.Filter
+
+	count, total int64
 }
 
 func (*Cmd) Name() string     { return "ls" }
@@ -38,8 +49,14 @@ func (*Cmd) Usage() string {
 }
 
 func (c *Cmd) SetFlags(f *flag.FlagSet) {
-	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs, b2)")
+	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs), or SSH private key (sftp)")
 	f.BoolVar(&c.hidden, "hidden", false, "list hidden files as well (b2)")
+	f.StringVar(&c.format, "format", format.Text, "output format: text, json, or jsonl")
+	f.BoolVar(&c.long, "l", false, "long listing: size, upload time, content type, and with -hidden, version")
+	f.BoolVar(&c.human, "h", false, "print sizes in human-readable form (with -l)")
+	f.BoolVar(&c.recurse, "r", false, "list every object under the path, not just one level")
+	f.Var(&c.filter.Include, "include", "list only names matching this glob pattern, where ** matches any number of directories; may be repeated")
+	f.Var(&c.filter.Exclude, "exclude", "don't list names matching this glob pattern; may be repeated")
 }
 
 func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -49,6 +66,11 @@ func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) su
 		return subcommands.ExitUsageError
 	}
 
+	if err := format.Check(c.format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return subcommands.ExitUsageError
+	}
+
 	pathArg := f.Args()[0]
 
 	path, err := c.parseURI(ctx, pathArg)
@@ -57,14 +79,29 @@ func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) su
 		return subcommands.ExitFailure
 	}
 
-	names, errs, err := path.List(ctx)
+	objs, errs, err := path.List(ctx)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return subcommands.ExitFailure
 	}
 
-	for name := range names {
-		fmt.Println(name)
+	text := printName
+	if c.long {
+		text = c.printLong
+	}
+	out := format.NewList(os.Stdout, c.format, text)
+	for obj := range objs {
+		if !c.filter.Match(strings.TrimSuffix(obj.Name, "/")) {
+			continue
+		}
+		if err := out.Write(obj); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return subcommands.ExitFailure
+		}
+	}
+	if err := out.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return subcommands.ExitFailure
 	}
 
 	if err, ok := <-errs; ok {
@@ -72,35 +109,68 @@ func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) su
 		return subcommands.ExitFailure
 	}
 
-	return subcommands.ExitSuccess
-}
+	if c.long && c.format == format.Text {
+		fmt.Printf("total: %d objects, %s\n", c.count, c.size(c.total))
+	}
 
-type endpoint interface {
-	List(context.Context) (chan string, chan error, error)
+	return subcommands.ExitSuccess
 }
 
-func (c *Cmd) parseURI(ctx context.Context, uri string) (endpoint, error) {
-	url, err := url.Parse(uri)
+func (c *Cmd) parseURI(ctx context.Context, uri string) (backend.Lister, error) {
+	ep, err := backend.Open(ctx, uri, &backend.Options{
+		Auth:      c.auth,
+		Hidden:    c.hidden,
+		Recursive: c.recurse,
+		Detail:    c.long || c.format != format.Text,
+	})
 	if err != nil {
 		return nil, err
 	}
-	switch url.Scheme {
-	/*case "gcs":
-	ep, err := gcs.New(ctx, c.auth, url)
-	if err != nil {
-		return nil, err
+	l, ok := ep.(backend.Lister)
+	if !ok {
+		return nil, backend.UnsupportedError{Op: "list"}
 	}
-	return ep, nil
-	*/
-	case "b2":
-		ep, err := b2.New(ctx, url)
-		if err != nil {
-			return nil, err
-		}
-		ep.Hidden = c.hidden
-		return ep, nil
-		//case "file", "":
-		//	return file.Path(url.Path), nil
+	return l, nil
+}
+
+func printName(w io.Writer, a *backend.Attrs) error {
+	_, err := fmt.Fprintln(w, a.Name)
+	return err
+}
+
+func (c *Cmd) size(s int64) string {
+	if c.human {
+		return format.Size(s)
 	}
-	return nil, fmt.Errorf("%s: unknown scheme", url.Scheme)
+	return strconv.FormatInt(s, 10)
 }
+
+func (c *Cmd) printLong(w io.Writer, a *backend.Attrs) error {
+	if a.Prefix {
+		_, err := fmt.Fprintf(w, "%12s  %s\n", "PRE", a.Name)
+		return err
+	}
+	c.count++
+	c.total += a.Size
+
+	t := a.Uploaded
+	if t.IsZero() {
+		t = a.LastModified
+	}
+	ct := a.ContentType
+	if ct == "" {
+		ct = "-"
+	}
+	if !c.hidden {
+		_, err := fmt.Fprintf(w, "%12s  %s  %-24s  %s\n", c.size(a.Size), t.Format(timeFormat), ct, a.Name)
+		return err
+	}
+	hide := "-"
+	if a.Hider {
+		hide = "H"
+	}
+	_, err := fmt.Fprintf(w, "%12s  %s  %-24s  %s %s  %s\n", c.size(a.Size), t.Format(timeFormat), ct, hide, a.Version, a.Name)
+	return err
+}
+
+const timeFormat = "2006-01-02 15:04:05"
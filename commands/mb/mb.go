@@ -0,0 +1,75 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mb
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/subcommands"
+	"github.com/kurin/cloudpipe/backend"
+)
+
+type Cmd struct {
+	auth  string
+	attrs backend.BucketAttrs
+}
+
+func (*Cmd) Name() string     { return "mb" }
+func (*Cmd) Synopsis() string { return "Make a bucket." }
+
+func (*Cmd) Usage() string {
+	return "mb [flags] bucket\n"
+}
+
+func (c *Cmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs)")
+	f.BoolVar(&c.attrs.Public, "public", false, "allow anyone to read the bucket's objects (b2, az)")
+	f.StringVar(&c.attrs.Project, "project", "", "project to create the bucket in; defaults to the key file's project (gcs)")
+	f.StringVar(&c.attrs.Location, "location", "", "where to keep the bucket's data, e.g. US (gcs) or eu-west-1 (s3); defaults to the backend's default")
+	f.StringVar(&c.attrs.StorageClass, "class", "", "default storage class, e.g. NEARLINE (gcs)")
+}
+
+func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "%s", c.Usage())
+		f.PrintDefaults()
+		return subcommands.ExitUsageError
+	}
+
+	mbArg := f.Args()[0]
+
+	ep, err := backend.Open(ctx, mbArg, &backend.Options{Auth: c.auth})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", mbArg, err)
+		return subcommands.ExitFailure
+	}
+	if ns, ok := ep.(backend.Namespace); ok && ns.Name() != "" {
+		fmt.Fprintf(os.Stderr, "%s: not a bucket\n", mbArg)
+		return subcommands.ExitUsageError
+	}
+	bm, ok := ep.(backend.BucketMaker)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", mbArg, backend.UnsupportedError{Op: "making buckets"})
+		return subcommands.ExitFailure
+	}
+	if err := bm.MakeBucket(ctx, &c.attrs); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", mbArg, err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
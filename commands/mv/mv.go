@@ -0,0 +1,280 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mv
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/subcommands"
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/object"
+)
+
+// Cmd moves objects by copying them, verifying the copies, and then removing
+// the originals.
+type Cmd struct {
+	conns     int
+	part      int64
+	auth      string
+	recurse   bool
+	threads   int
+	deleteBad bool
+	checksum  string
+	all       bool
+}
+
+func (*Cmd) Name() string     { return "mv" }
+func (*Cmd) Synopsis() string { return "Move a file." }
+
+func (*Cmd) Usage() string {
+	return `mv [flags] source destination
+
+Objects are copied within the backend where possible (gcs, s3), and streamed
+otherwise.  Each source object is removed only once its copy is verified.
+With -r, local directories left empty by the move are removed too.
+`
+}
+
+func (c *Cmd) SetFlags(f *flag.FlagSet) {
+	f.IntVar(&c.conns, "connections", 4, "number of concurrent connections (b2, s3, az, sftp)")
+	f.Int64Var(&c.part, "part_size", 0, "size in bytes of each part of a multipart upload, at least 5MiB for s3; 0 uses the default (s3, az)")
+	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs), or SSH private key (sftp)")
+	f.BoolVar(&c.recurse, "r", false, "move every object under the source path to the destination path")
+	f.IntVar(&c.threads, "threads", 4, "move this many objects in parallel (with -r)")
+	f.BoolVar(&c.deleteBad, "delete_bad", false, "delete destination objects that fail verification")
+	f.StringVar(&c.checksum, "checksum", "sha1", "checksum used to verify local files: sha1, md5, or crc32c (file)")
+	f.BoolVar(&c.all, "all", false, "remove every version of each source object, so that no older version takes its place (b2, s3)")
+}
+
+func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "%s", c.Usage())
+		f.PrintDefaults()
+		return subcommands.ExitUsageError
+	}
+
+	srcArg := f.Args()[0]
+	dstArg := f.Args()[1]
+
+	if srcArg == "-" || dstArg == "-" {
+		fmt.Fprintln(os.Stderr, "mv can't read from or write to -")
+		return subcommands.ExitUsageError
+	}
+	if _, err := backend.NewHash(c.checksum); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return subcommands.ExitUsageError
+	}
+
+	src, err := c.open(ctx, srcArg, c.recurse)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", srcArg, err)
+		return subcommands.ExitFailure
+	}
+	// The destination is never opened recursively, so that removing an
+	// object that fails verification can't remove anything else.
+	dst, err := c.open(ctx, dstArg, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", dstArg, err)
+		return subcommands.ExitFailure
+	}
+	// Source objects are removed through an endpoint opened non-recursively,
+	// so that removing one can't remove anything else.
+	from, err := backend.Open(ctx, srcArg, &backend.Options{
+		Auth:        c.auth,
+		AllVersions: c.all,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", srcArg, err)
+		return subcommands.ExitFailure
+	}
+	fromNS, ok := from.(backend.Namespace)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", srcArg, backend.UnsupportedError{Op: "move"})
+		return subcommands.ExitFailure
+	}
+
+	if overlaps(where(srcArg, src), where(dstArg, dst), c.recurse) {
+		fmt.Fprintf(os.Stderr, "can't move %s to %s: they overlap\n", srcArg, dstArg)
+		return subcommands.ExitUsageError
+	}
+
+	if !c.recurse {
+		if err := c.move(ctx, src, dst, fromNS); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return subcommands.ExitFailure
+		}
+		return subcommands.ExitSuccess
+	}
+
+	var moved, failed int64
+	err = object.Tree(ctx, src, c.threads, func(name, rel string) {
+		to := object.DirName(dst.Name()) + rel
+		if err := c.move(ctx, src.Object(name), dst.Object(to), fromNS); err != nil {
+			atomic.AddInt64(&failed, 1)
+			fmt.Fprintf(os.Stderr, "%s -> %s: %v\n", name, to, err)
+			return
+		}
+		atomic.AddInt64(&moved, 1)
+		fmt.Printf("%s -> %s\n", name, to)
+	})
+	status := subcommands.ExitSuccess
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", srcArg, err)
+		status = subcommands.ExitFailure
+	}
+	fmt.Fprintf(os.Stderr, "%d moved, %d failed\n", moved, failed)
+	if failed > 0 {
+		status = subcommands.ExitFailure
+	}
+	if local(srcArg) {
+		removeEmptyDirs(src.Name())
+	}
+	return status
+}
+
+func (c *Cmd) open(ctx context.Context, uri string, recurse bool) (backend.Namespace, error) {
+	ep, err := backend.Open(ctx, uri, &backend.Options{
+		Auth:        c.auth,
+		Connections: c.conns,
+		PartSize:    c.part,
+		Recursive:   recurse,
+		Checksum:    c.checksum,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ns, ok := ep.(backend.Namespace)
+	if !ok {
+		return nil, backend.UnsupportedError{Op: "move"}
+	}
+	return ns, nil
+}
+
+// move copies src to dst, within the backend if it can, verifies the copy,
+// and then removes the source object through from.
+func (c *Cmd) move(ctx context.Context, src, dst backend.Endpoint, from backend.Namespace) error {
+	ns, ok := src.(backend.Namespace)
+	if !ok || ns.Name() == "" {
+		return backend.UnsupportedError{Op: "move"}
+	}
+
+	copied := false
+	if cp, ok := dst.(backend.Copier); ok {
+		err := cp.CopyFrom(ctx, src)
+		if _, unsupported := err.(backend.UnsupportedError); !unsupported {
+			if err != nil {
+				return err
+			}
+			if err := object.Compare(ctx, src, dst); err != nil {
+				return err
+			}
+			copied = true
+		}
+	}
+	if !copied {
+		if err := c.copy(ctx, src, dst); err != nil {
+			return err
+		}
+	}
+
+	if err := object.Remove(ctx, from.Object(ns.Name())); err != nil {
+		return fmt.Errorf("%s: copied, but not removed: %v", ns.Name(), err)
+	}
+	return nil
+}
+
+// copy streams src to dst, and checks that what the destination reports
+// matches what was read.
+func (c *Cmd) copy(ctx context.Context, src, dst backend.Endpoint) error {
+	v, err := object.NewVerifier(dst)
+	if err != nil {
+		return err
+	}
+	srcR, ok := src.(backend.Reader)
+	if !ok {
+		return backend.UnsupportedError{Op: "read"}
+	}
+	r, err := srcR.Reader(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := object.Write(ctx, dst, io.TeeReader(r, v)); err != nil {
+		return err
+	}
+	verr := v.Check(ctx)
+	if verr == nil || !c.deleteBad {
+		return verr
+	}
+	return object.RemoveBad(ctx, dst, verr)
+}
+
+// local reports whether uri names a local file.
+func local(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && (u.Scheme == "" || u.Scheme == "file")
+}
+
+// where returns the location of the object or path ns, opened from uri, in a
+// form that can be compared with others.
+func where(uri string, ns backend.Namespace) string {
+	if local(uri) {
+		name, err := filepath.Abs(ns.Name())
+		if err != nil {
+			name = ns.Name()
+		}
+		return "file://" + filepath.ToSlash(name)
+	}
+	u, _ := url.Parse(uri)
+	return u.Scheme + "://" + u.Host + "/" + ns.Name()
+}
+
+// overlaps reports whether moving src to dst would overwrite the source: if
+// they are the same, or when moving a tree, if either lies beneath the other.
+func overlaps(src, dst string, tree bool) bool {
+	if src == dst {
+		return true
+	}
+	if !tree {
+		return false
+	}
+	src, dst = object.DirName(src), object.DirName(dst)
+	return strings.HasPrefix(src, dst) || strings.HasPrefix(dst, src)
+}
+
+// removeEmptyDirs removes root, and every directory beneath it, that holds
+// nothing.
+func removeEmptyDirs(root string) {
+	var dirs []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	// Walk visits each directory before those beneath it, so removing them
+	// in reverse empties the deepest first.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+}
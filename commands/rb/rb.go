@@ -0,0 +1,135 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rb
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/subcommands"
+	"github.com/kurin/cloudpipe/backend"
+)
+
+type Cmd struct {
+	auth    string
+	force   bool
+	threads int
+}
+
+func (*Cmd) Name() string     { return "rb" }
+func (*Cmd) Synopsis() string { return "Remove a bucket." }
+
+func (*Cmd) Usage() string {
+	return `rb [flags] bucket
+
+Buckets that hold any objects, including hidden objects and old versions, are
+only removed with -force, which deletes everything in them first.
+`
+}
+
+func (c *Cmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs)")
+	f.BoolVar(&c.force, "force", false, "delete every object and version in the bucket, then the bucket")
+	f.IntVar(&c.threads, "threads", 1, "delete this many objects in parallel, with -force (b2, s3, az)")
+}
+
+func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "%s", c.Usage())
+		f.PrintDefaults()
+		return subcommands.ExitUsageError
+	}
+
+	rbArg := f.Args()[0]
+
+	ep, err := backend.Open(ctx, rbArg, &backend.Options{
+		Auth:        c.auth,
+		Hidden:      true,
+		Recursive:   true,
+		AllVersions: true,
+		Threads:     c.threads,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", rbArg, err)
+		return subcommands.ExitFailure
+	}
+	if ns, ok := ep.(backend.Namespace); ok && ns.Name() != "" {
+		fmt.Fprintf(os.Stderr, "%s: not a bucket\n", rbArg)
+		return subcommands.ExitUsageError
+	}
+	br, ok := ep.(backend.BucketRemover)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", rbArg, backend.UnsupportedError{Op: "removing buckets"})
+		return subcommands.ExitFailure
+	}
+
+	empty, err := isEmpty(ctx, ep)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", rbArg, err)
+		return subcommands.ExitFailure
+	}
+	if !empty {
+		if !c.force {
+			fmt.Fprintf(os.Stderr, "%s: bucket not empty; use -force to delete its contents too\n", rbArg)
+			return subcommands.ExitFailure
+		}
+		rm, ok := ep.(backend.Remover)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", rbArg, backend.UnsupportedError{Op: "remove"})
+			return subcommands.ExitFailure
+		}
+		err := rm.Remove(ctx)
+		if sr, ok := rm.(backend.StatsRemover); ok {
+			fmt.Fprintln(os.Stderr, sr.RemoveStats())
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", rbArg, err)
+			return subcommands.ExitFailure
+		}
+	}
+
+	if err := br.RemoveBucket(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", rbArg, err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// isEmpty reports whether ep lists nothing, stopping at the first object.
+func isEmpty(ctx context.Context, ep backend.Endpoint) (bool, error) {
+	l, ok := ep.(backend.Lister)
+	if !ok {
+		return false, backend.UnsupportedError{Op: "list"}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	objs, errs, err := l.List(ctx)
+	if err != nil {
+		return false, err
+	}
+	empty := true
+	for range objs {
+		if empty {
+			empty = false
+			cancel()
+		}
+	}
+	if err, ok := <-errs; ok && err != nil && empty {
+		return false, err
+	}
+	return empty, nil
+}
@@ -18,11 +18,10 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"net/url"
 	"os"
 
 	"github.com/google/subcommands"
-	"github.com/kurin/cloudpipe/backends/b2"
+	"github.com/kurin/cloudpipe/backend"
 )
 
 type Cmd struct {
@@ -35,19 +34,22 @@ type Cmd struct {
 }
 
 func (*Cmd) Name() string     { return "rm" }
-func (*Cmd) Synopsis() string { return "Remove an object or bucket." }
+func (*Cmd) Synopsis() string { return "Remove an object." }
 
 func (*Cmd) Usage() string {
-	return "rm [flags] file\n"
+	return `rm [flags] file
+
+Buckets are never removed; use rb for that.
+`
 }
 
 func (c *Cmd) SetFlags(f *flag.FlagSet) {
-	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs, b2)")
+	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs), or SSH private key (sftp)")
 	f.BoolVar(&c.hide, "hide", false, "hide an object instead of deleting it (b2)")
 	f.BoolVar(&c.hidden, "hidden", false, "operate on hidden files as well (b2)")
 	f.BoolVar(&c.all, "all", false, "remove all versions of a file, not just the most recent (b2)")
-	f.BoolVar(&c.recurse, "r", false, "recursively delete objects under a given path (b2, gcs)")
-	f.IntVar(&c.threads, "threads", 1, "remove this many objects in parallel (b2, gcs)")
+	f.BoolVar(&c.recurse, "r", false, "recursively delete objects under a given path")
+	f.IntVar(&c.threads, "threads", 1, "remove this many objects in parallel (b2, s3, az)")
 }
 
 func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -65,7 +67,16 @@ func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) su
 		return subcommands.ExitFailure
 	}
 
-	if err := rm.Remove(ctx); err != nil {
+	if ns, ok := rm.(backend.Namespace); ok && ns.Name() == "" && !c.recurse {
+		fmt.Fprintf(os.Stderr, "%s: no object named; use -r to remove every object, or rb to remove the bucket\n", rmArg)
+		return subcommands.ExitUsageError
+	}
+
+	err = rm.Remove(ctx)
+	if sr, ok := rm.(backend.StatsRemover); ok && (c.recurse || c.all) {
+		fmt.Fprintln(os.Stderr, sr.RemoveStats())
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return subcommands.ExitFailure
 	}
@@ -73,35 +84,21 @@ func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) su
 	return subcommands.ExitSuccess
 }
 
-type endpoint interface {
-	Remove(context.Context) error
-}
-
-func (c *Cmd) parseURI(ctx context.Context, uri string) (endpoint, error) {
-	url, err := url.Parse(uri)
-	if err != nil {
-		return nil, err
-	}
-	switch url.Scheme {
-	/*case "gcs":
-	ep, err := gcs.New(ctx, c.auth, url)
+func (c *Cmd) parseURI(ctx context.Context, uri string) (backend.Remover, error) {
+	ep, err := backend.Open(ctx, uri, &backend.Options{
+		Auth:        c.auth,
+		Hide:        c.hide,
+		Hidden:      c.hidden,
+		Recursive:   c.recurse,
+		AllVersions: c.all,
+		Threads:     c.threads,
+	})
 	if err != nil {
 		return nil, err
 	}
-	return ep, nil
-	*/
-	case "b2":
-		ep, err := b2.New(ctx, url)
-		if err != nil {
-			return nil, err
-		}
-		ep.Hide = c.hide
-		ep.Hidden = c.hidden
-		ep.Recursive = c.recurse
-		ep.Bucket = url.Path == ""
-		return ep, nil
-		//case "file", "":
-		//	return file.Path(url.Path), nil
+	r, ok := ep.(backend.Remover)
+	if !ok {
+		return nil, backend.UnsupportedError{Op: "remove"}
 	}
-	return nil, fmt.Errorf("%s: unknown scheme", url.Scheme)
+	return r, nil
 }
@@ -0,0 +1,51 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3config
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/google/subcommands"
+	"github.com/kurin/cloudpipe/backends/s3"
+)
+
+type Cmd struct {
+	cfg s3.Config
+}
+
+func (*Cmd) Name() string     { return "s3config" }
+func (*Cmd) Synopsis() string { return "Set S3 account options." }
+
+func (*Cmd) Usage() string {
+	return "s3config [flags]\n"
+}
+
+func (c *Cmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.cfg.ID, "id", "", "Access key ID; if empty, the usual AWS credentials are used.")
+	f.StringVar(&c.cfg.Key, "key", "", "Secret access key.")
+	f.StringVar(&c.cfg.Region, "region", "", "Region; if empty, the usual AWS configuration is used.")
+	f.StringVar(&c.cfg.Endpoint, "endpoint", "", "URL of an S3-compatible service, e.g. http://localhost:9000.")
+	f.BoolVar(&c.cfg.PathStyle, "path_style", false, "Address buckets by path rather than host name, as MinIO and Ceph RGW usually require.")
+}
+
+func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if err := s3.Save(&c.cfg); err != nil {
+		log.Printf("s3config: %v", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -18,24 +18,30 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"net/url"
 	"os"
 
 	"github.com/google/subcommands"
-	"github.com/kurin/cloudpipe/backends/b2"
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/format"
 )
 
 type Cmd struct {
+	auth    string
+	format  string
+	headers backend.Headers
 }
 
 func (*Cmd) Name() string     { return "stat" }
 func (*Cmd) Synopsis() string { return "Print information about an object." }
 
 func (*Cmd) Usage() string {
-	return "stat path\n"
+	return "stat [flags] path\n"
 }
 
 func (c *Cmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs), or SSH private key (sftp)")
+	f.StringVar(&c.format, "format", format.Text, "output format: text, json, or jsonl")
+	f.Var(&c.headers, "header", "add this \"Name: value\" header to requests; may be repeated (http)")
 }
 
 func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -45,6 +51,11 @@ func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) su
 		return subcommands.ExitUsageError
 	}
 
+	if err := format.Check(c.format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return subcommands.ExitUsageError
+	}
+
 	statArg := f.Args()[0]
 
 	stat, err := c.parseURI(ctx, statArg)
@@ -53,33 +64,28 @@ func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) su
 		return subcommands.ExitFailure
 	}
 
-	txt, err := stat.Stat(ctx)
+	attrs, err := stat.Stat(ctx)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return subcommands.ExitFailure
 	}
 
-	fmt.Print(txt)
+	if err := format.Object(os.Stdout, c.format, attrs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return subcommands.ExitFailure
+	}
 
 	return subcommands.ExitSuccess
 }
 
-type endpoint interface {
-	Stat(context.Context) (string, error)
-}
-
-func (c *Cmd) parseURI(ctx context.Context, uri string) (endpoint, error) {
-	url, err := url.Parse(uri)
+func (c *Cmd) parseURI(ctx context.Context, uri string) (backend.Statter, error) {
+	ep, err := backend.Open(ctx, uri, &backend.Options{Auth: c.auth, Headers: c.headers})
 	if err != nil {
 		return nil, err
 	}
-	switch url.Scheme {
-	case "b2":
-		ep, err := b2.New(ctx, url)
-		if err != nil {
-			return nil, err
-		}
-		return ep, nil
+	s, ok := ep.(backend.Statter)
+	if !ok {
+		return nil, backend.UnsupportedError{Op: "stat"}
 	}
-	return nil, fmt.Errorf("%s: unknown scheme", url.Scheme)
+	return s, nil
 }
@@ -0,0 +1,333 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/subcommands"
+	"github.com/kurin/cloudpipe/backend"
+	"github.com/kurin/cloudpipe/internal/glob"
+	"github.com/kurin/cloudpipe/internal/object"
+)
+
+type Cmd struct {
+	auth     string
+	conns    int
+	threads  int
+	checksum bool
+	delete   bool
+	hide     bool
+	dryRun   bool
+	filter   glob.Filter
+}
+
+func (*Cmd) Name() string     { return "sync" }
+func (*Cmd) Synopsis() string { return "Make a destination path match a source path." }
+
+func (*Cmd) Usage() string {
+	return `sync [flags] source destination
+
+Copy every object beneath source that is missing from destination, or that
+differs from its copy there.  Objects differ if their sizes differ, or if the
+source was modified after the destination was written; with -checksum, they
+differ if their checksums differ.
+`
+}
+
+func (c *Cmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.auth, "auth", "", "path to JSON key file (gcs), or SSH private key (sftp)")
+	f.IntVar(&c.conns, "connections", 4, "number of concurrent connections per object (b2, s3, az, sftp, http)")
+	f.IntVar(&c.threads, "threads", 4, "copy or remove this many objects in parallel")
+	f.BoolVar(&c.checksum, "checksum", false, "compare checksums instead of modification times; local files must be read in full")
+	f.BoolVar(&c.delete, "delete", false, "delete destination objects that aren't in the source")
+	f.BoolVar(&c.hide, "hide", false, "hide destination objects that aren't in the source, instead of deleting them (b2)")
+	f.BoolVar(&c.dryRun, "dry-run", false, "report what would change without changing anything")
+	f.Var(&c.filter.Include, "include", "sync only names matching this glob pattern, relative to the source; may be repeated")
+	f.Var(&c.filter.Exclude, "exclude", "don't sync names matching this glob pattern, relative to the source; may be repeated")
+}
+
+// An action is a change to make to the destination.
+type action struct {
+	kind string // "new", "changed", "delete", or "hide"
+	rel  string
+}
+
+// summary counts what a sync did, or would do.
+type summary struct {
+	new, changed, unchanged, deleted, hidden, failed int64
+}
+
+func (s *summary) String() string {
+	return fmt.Sprintf("%d new, %d changed, %d unchanged, %d deleted, %d hidden, %d failed",
+		s.new, s.changed, s.unchanged, s.deleted, s.hidden, s.failed)
+}
+
+func (c *Cmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "%s", c.Usage())
+		f.PrintDefaults()
+		return subcommands.ExitUsageError
+	}
+	if c.delete && c.hide {
+		fmt.Fprintln(os.Stderr, "-delete and -hide can't be used together")
+		return subcommands.ExitUsageError
+	}
+
+	srcArg := f.Args()[0]
+	dstArg := f.Args()[1]
+
+	// The destination is opened twice: recursively to list it, and not, so
+	// that removing an extra object can't remove anything beneath it.
+	dstList, err := c.open(ctx, dstArg, true, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", dstArg, err)
+		return subcommands.ExitFailure
+	}
+	dst, err := c.open(ctx, dstArg, false, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", dstArg, err)
+		return subcommands.ExitFailure
+	}
+
+	// Local sources compute the checksum the destination reports.
+	var sum string
+	if cs, ok := dst.Endpoint.(backend.Checksummer); ok && c.checksum {
+		sum = cs.Checksums()[0]
+	}
+	src, err := c.open(ctx, srcArg, true, sum)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", srcArg, err)
+		return subcommands.ExitFailure
+	}
+
+	dstObjs, err := c.list(ctx, dstList, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", dstArg, err)
+		return subcommands.ExitFailure
+	}
+	srcObjs, err := c.list(ctx, src, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", srcArg, err)
+		return subcommands.ExitFailure
+	}
+
+	sum2 := &summary{}
+	var actions []action
+	for rel, sa := range srcObjs {
+		da, ok := dstObjs[rel]
+		switch {
+		case !ok:
+			actions = append(actions, action{"new", rel})
+		case c.differ(ctx, src.Object(src.base+rel), dst.Object(dst.base+rel), sa, da):
+			actions = append(actions, action{"changed", rel})
+		default:
+			sum2.unchanged++
+		}
+	}
+	if c.delete || c.hide {
+		kind := "delete"
+		if c.hide {
+			kind = "hide"
+		}
+		for rel := range dstObjs {
+			if _, ok := srcObjs[rel]; !ok {
+				actions = append(actions, action{kind, rel})
+			}
+		}
+	}
+
+	c.apply(ctx, src, dst, actions, sum2)
+
+	if c.dryRun {
+		fmt.Fprintf(os.Stderr, "%v (dry run)\n", sum2)
+	} else {
+		fmt.Fprintln(os.Stderr, sum2)
+	}
+	if sum2.failed > 0 {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// A side is one end of a sync: an endpoint, treated as a directory.
+type side struct {
+	backend.Endpoint
+	ns   backend.Namespace
+	base string
+}
+
+// Object returns the named object on this side.
+func (s *side) Object(name string) backend.Endpoint { return s.ns.Object(name) }
+
+func (c *Cmd) open(ctx context.Context, uri string, recurse bool, sum string) (*side, error) {
+	ep, err := backend.Open(ctx, uri, &backend.Options{
+		Auth:        c.auth,
+		Connections: c.conns,
+		Recursive:   recurse,
+		Hide:        c.hide,
+		Checksum:    sum,
+		Detail:      true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ns, ok := ep.(backend.Namespace)
+	if !ok {
+		return nil, backend.UnsupportedError{Op: "sync"}
+	}
+	s := &side{Endpoint: ep, ns: ns, base: object.DirName(ns.Name())}
+	if s.base != "" {
+		// Treat the path as a directory, so that b2://bucket/dir doesn't
+		// also match b2://bucket/directory.
+		s.Endpoint = ns.Object(s.base)
+	}
+	return s, nil
+}
+
+// list returns the objects beneath s, by name relative to it.  If missingOK is
+// set, a path that doesn't exist is listed as empty.
+func (c *Cmd) list(ctx context.Context, s *side, missingOK bool) (map[string]*backend.Attrs, error) {
+	l, ok := s.Endpoint.(backend.Lister)
+	if !ok {
+		return nil, backend.UnsupportedError{Op: "list"}
+	}
+	ach, ech, err := l.List(ctx)
+	if err != nil {
+		if missingOK && os.IsNotExist(err) {
+			// A destination that doesn't exist yet is empty.
+			return nil, nil
+		}
+		return nil, err
+	}
+	objs := make(map[string]*backend.Attrs)
+	for a := range ach {
+		if a.Prefix || a.Hider || !strings.HasPrefix(a.Name, s.base) {
+			continue
+		}
+		rel := strings.TrimPrefix(a.Name, s.base)
+		if rel == "" || strings.HasSuffix(rel, "/") || !c.filter.Match(rel) {
+			continue
+		}
+		objs[rel] = a
+	}
+	if err := <-ech; err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
+
+// differ reports whether the source object needs to be copied over its
+// destination.
+func (c *Cmd) differ(ctx context.Context, src, dst backend.Endpoint, sa, da *backend.Attrs) bool {
+	if sa.Size != da.Size {
+		return true
+	}
+	if cs, ok := dst.(backend.Checksummer); ok && c.checksum {
+		for _, name := range cs.Checksums() {
+			want := checksum(ctx, dst, da, name)
+			if want == "" {
+				continue
+			}
+			if got := checksum(ctx, src, sa, name); got != "" {
+				return got != want
+			}
+		}
+		// With no checksum in common, fall back to modification times.
+	}
+	st, dt := sa.LastModified, da.LastModified
+	if st.IsZero() {
+		st = sa.Uploaded
+	}
+	if dt.IsZero() {
+		dt = da.Uploaded
+	}
+	return st.After(dt)
+}
+
+// checksum returns the named checksum from a, or failing that, from ep's
+// Stat; local files are only checksummed by Stat.
+func checksum(ctx context.Context, ep backend.Endpoint, a *backend.Attrs, name string) string {
+	if sum := a.Checksum(name); sum != "" {
+		return sum
+	}
+	st, ok := ep.(backend.Statter)
+	if !ok {
+		return ""
+	}
+	a, err := st.Stat(ctx)
+	if err != nil {
+		return ""
+	}
+	return a.Checksum(name)
+}
+
+// apply carries out the actions with c.threads workers, reporting each one,
+// and counting them in s.
+func (c *Cmd) apply(ctx context.Context, src, dst *side, actions []action, s *summary) {
+	threads := c.threads
+	if threads < 1 {
+		threads = 1
+	}
+	var wg sync.WaitGroup
+	ach := make(chan action)
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for a := range ach {
+				to := dst.base + a.rel
+				var err error
+				if !c.dryRun {
+					switch a.kind {
+					case "new", "changed":
+						err = object.Copy(ctx, src.Object(src.base+a.rel), dst.Object(to))
+					case "delete", "hide":
+						err = object.Remove(ctx, dst.Object(to))
+					}
+				}
+				if err != nil {
+					atomic.AddInt64(&s.failed, 1)
+					fmt.Fprintf(os.Stderr, "%s %s: %v\n", a.kind, to, err)
+					continue
+				}
+				var n *int64
+				switch a.kind {
+				case "new":
+					n = &s.new
+				case "changed":
+					n = &s.changed
+				case "delete":
+					n = &s.deleted
+				case "hide":
+					n = &s.hidden
+				}
+				atomic.AddInt64(n, 1)
+				fmt.Printf("%s %s\n", a.kind, to)
+			}
+		}()
+	}
+	for _, a := range actions {
+		ach <- a
+	}
+	close(ach)
+	wg.Wait()
+}
@@ -0,0 +1,237 @@
+// Code generated by go-bindata.
+// sources:
+// data/status.html
+// DO NOT EDIT!
+
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func bindataRead(data []byte, name string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("Read %q: %v", name, err)
+	}
+
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, gz)
+	clErr := gz.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("Read %q: %v", name, err)
+	}
+	if clErr != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type asset struct {
+	bytes []byte
+	info  os.FileInfo
+}
+
+type bindataFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi bindataFileInfo) Name() string {
+	return fi.name
+}
+func (fi bindataFileInfo) Size() int64 {
+	return fi.size
+}
+func (fi bindataFileInfo) Mode() os.FileMode {
+	return fi.mode
+}
+func (fi bindataFileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+func (fi bindataFileInfo) IsDir() bool {
+	return false
+}
+func (fi bindataFileInfo) Sys() interface{} {
+	return nil
+}
+
+var _dataStatusHtml = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xe5\x59\x4b\x73\xdb\x38\x12\x3e\xdb\xbf\x02\x61\x72\x20\x13\x99\x94\x3d\xc9\x1c\xf4\x4a\x6d\x32\xc9\xce\x6e\x55\x32\x5b\x71\xa6\xf6\xe0\x4a\x6d\x51\x04\x24\x62\x4c\x11\x2c\x00\x92\xac\xd5\xf0\xbf\x6f\x37\x1e\x14\xa8\x47\x36\xb5\xd7\x3d\xd8\x96\x80\xee\x0f\xfd\xee\x06\x3c\x29\xf5\xaa\x9a\x5d\x4f\x4a\x96\x53\xf8\xa3\xb9\xae\xd8\xac\xa8\xc4\x9a\x36\xbc\x61\x44\xe9\x5c\xaf\xd5\x24\xb3\xeb\xd7\x13\xa5\x77\xf8\x97\x10\xb5\x59\xa6\x32\xd7\x8c\xec\xc9\x86\x49\xcd\x8b\xbc\xba\xc9\x2b\xbe\xac\x47\x64\xc5\x29\xad\xd8\x98\xcc\x85\xa4\x4c\x8e\xc8\x6d\xf3\x44\x94\xa8\x38\x25\xcf\x8b\xa2\x18\x93\x36\x64\x6f\x44\xb5\xab\x78\x8d\x38\x0b\x5e\x55\x23\x52\x8b\x1a\x78\x95\x96\xe2\x91\x8d\xc8\xf3\x9f\x7e\x2e\xfc\xb7\x9b\x2d\xa7\xba\x04\xbc\xf4\x8d\x05\x49\x17\x39\xaf\x18\x05\xd6\x42\x54\x02\x4e\x7a\x5e\x0c\x87\xb8\x35\xc9\x9c\x9c\x93\xcc\xe9\x35\x17\x74\x07\x7f\x28\xdf\x10\x4e\xa7\x91\x55\x2b\x42\x45\x26\xe5\xed\x6c\xc5\x74\x29\x28\xa9\x40\xa0\xba\xd8\x01\xd3\x2d\xee\x10\xb2\xdf\xcb\xbc\x5e\x32\xf2\xc2\x12\x0c\xc8\x8b\x92\x2b\x4d\x46\x53\x92\x7e\x32\x2b\xbf\xc2\xd7\xb6\x35\xb4\x93\xf2\x6e\xb6\xdf\x7b\x52\xd2\xb6\x00\x73\x67\x61\x26\x3a\x9f\x5b\xab\xf5\x40\x79\x4d\xd9\x13\x60\x16\x62\x5d\x1b\x50\x83\xee\xe0\x90\x4b\xce\x26\x9a\x02\x68\xf3\x25\x60\x40\x60\x58\xc5\x9d\x49\x23\xc5\x52\x32\xa5\xc8\x26\xaf\xd6\x6c\x1a\xed\xf7\x16\xac\x6d\x23\xb2\xca\x9f\x70\xa1\x12\xe2\xf1\xf7\x86\xbc\x48\xdf\xe7\x55\xa5\xbc\x78\x40\x30\x9b\x64\x9e\x7d\x66\x11\x33\x38\xb0\x93\x91\xd5\xd4\x2b\x96\x05\xe2\xfb\x8d\x2b\x34\xdb\xba\xa9\x44\x4e\x95\xb5\xd7\xd5\x55\xa7\x58\x9d\xaf\x18\xe8\x05\x42\x19\x53\xfd\x53\x72\xcd\xa4\x42\xae\x2b\x6f\x25\x24\xe9\x6c\x74\x85\xbc\x8a\xff\x9b\x19\x9e\xf4\xdd\x4e\x33\xa0\x26\x8a\xd7\x05\xc4\xc5\xde\x2c\xde\xeb\x5c\x6a\x46\xd3\x8f\x42\xae\x72\x4d\xa2\xdb\x37\xa3\xe1\xeb\xd1\xf0\x4d\x04\x20\x73\x49\x32\x87\xe2\x4d\x8b\xbe\x42\xf5\x8c\x59\x91\xff\x1f\x4e\x57\x23\x05\x10\x02\x36\x92\xc1\x31\xe7\xac\x88\x4b\x9d\x11\x6f\xfb\xb6\x0a\x4e\x73\xb6\xb8\xea\x59\x85\x8a\x6d\xfd\x43\x76\xf9\x02\xa1\xf9\x7f\x64\x97\x05\xaf\xb9\x2a\x19\xf5\x66\xf1\x59\x71\x10\xaf\x66\x5b\x06\xe9\x95\x7e\x74\x94\xd6\x32\x5a\x82\x54\x0b\x92\x7e\x90\x52\x48\x90\xab\xa8\x72\xa5\xa6\x91\xcd\xfd\xc8\x1d\xe1\x32\x05\xe9\x7e\x37\x51\xd9\xb6\xeb\x06\xf6\x2a\xc5\xda\x16\x3d\xe2\xe8\xba\xdc\xd9\xef\xd3\xcf\x60\xeb\xde\x8a\x31\xb5\x37\x73\xb0\x8e\xa8\x5f\xa0\x36\xb4\xad\xa7\xb1\xdf\x32\xe5\x4f\xb8\x39\x03\xff\x01\xb2\xf5\xbc\x5f\xfa\xc8\x4e\x2f\xe4\xf0\x9f\x2c\xa8\x78\xec\xa1\x9a\xf4\x0c\xad\xdb\x25\xe6\x24\x83\xba\x86\xc5\xb9\x90\xbc\xd1\xb3\xeb\x2c\x23\x5f\x4b\x28\xad\x39\xd8\x34\x9f\x8b\x0d\x23\x5c\x11\x09\x6c\x4c\x42\xb5\x14\x18\x3d\xf3\x1d\xd1\x40\xa2\x98\x84\xea\x3d\x26\x20\x07\x7e\x9d\x4b\xb1\x85\x25\xa2\xd6\x4d\x23\xa4\x56\x88\x64\x49\x6e\x14\x83\x1a\xc5\x36\xf0\x5b\x0d\x08\xd7\x16\x91\xca\x7c\x5b\x93\x85\x14\x2b\xd2\x05\x42\x66\x89\x48\xae\x10\x72\x47\x72\x29\xf9\x86\xa5\xd7\xf1\x62\x5d\x17\x9a\x8b\x3a\x4e\xc8\x1e\x6a\x09\x1c\x19\x3f\xdb\x42\x49\x13\xdb\xf4\x03\xb2\xdc\x8b\xb5\x2c\x98\xdd\x24\x00\xae\xd7\xb2\x1e\xc3\xe7\xf6\x1a\x7e\x81\x20\x58\x1c\x85\xdc\x91\x52\x54\xd4\x60\x03\x4d\x81\x52\xe9\x52\x8a\xf5\xb2\x6c\xd6\x9a\x88\x05\x61\x79\x51\x12\x0d\x01\xa5\x16\x4c\x82\xa8\x35\xe8\x0a\x0e\x25\x0d\x93\x16\x47\xb1\x42\xd4\x90\x07\x8f\x6c\x07\xd6\x00\x43\x50\x0e\x40\x28\x19\xc9\x6b\x4a\x30\x03\x53\xa0\xdc\xe4\xb2\x3b\x72\x4a\xf6\xed\xd8\xad\x41\xf4\xe9\xde\x42\x23\x38\xea\x3b\x25\x3f\x0f\xc7\x4e\x54\x13\x24\x2b\x8e\xee\x54\x64\x61\x22\x20\xbd\x87\x35\xc4\xf5\x66\x30\x44\x71\xed\xf5\x45\x24\xb5\x78\x42\x9c\xe8\xdd\xe3\xa7\xbf\x7e\x8d\xc6\x66\x1d\xb8\x49\x8c\x9b\x1c\x76\xa0\xb9\x71\x32\x31\x74\x69\xc5\xea\xa5\x2e\x61\xe1\xd5\x2b\x8f\x61\x8d\x5a\x03\xc5\xed\xf0\xee\xf5\x61\xd5\x5b\x93\xd4\xa9\x16\x1f\xf9\x13\xa3\xf1\x5d\x42\x5e\x19\x9c\x07\xfe\x6d\xec\xc8\x7c\xe3\xa9\x49\x36\x35\x08\x76\xa3\xbd\x0e\x11\xc8\x4b\xf2\x29\xd7\x65\xda\x88\x6d\x8c\x34\x83\x50\x1a\x04\x8d\xde\x45\x9d\xd7\x3a\x5d\x61\xa0\x28\x1e\x63\x15\x2a\x4b\x41\x1f\x48\x78\xf2\x0b\xa4\x12\xec\x38\x6d\x3d\x43\x93\x53\x63\x9b\xc3\xb9\xa8\x14\x79\x4b\xa2\x61\x04\x87\xd4\x64\x44\xee\xb5\xe4\xf5\x12\xa8\xc6\x7d\x11\x91\x95\xa6\x4b\xa6\x7f\x85\x80\x52\x71\x62\x84\x1a\x21\x57\xb7\xf3\x89\xd7\x6b\x88\x89\xb3\x7b\xf7\x26\x3c\x70\xef\x54\x0d\x56\xc5\x3a\x5f\x0e\x88\x66\x4f\x3a\xd4\x85\x81\x2e\x54\x14\xeb\x15\x84\x63\x5a\x48\x06\x2a\x7d\xa8\x18\x7e\x43\x7a\xa7\x1b\xfa\x06\x19\xc9\xb3\xe9\x94\xac\x21\x19\xa1\x20\x32\x7a\xf0\x12\x4b\x71\xf7\xbd\xa8\x35\x06\xf5\xd4\x1c\x72\xc6\x03\xec\x54\xac\x95\x32\x03\x42\xcc\x43\x99\x56\x10\xf9\xd3\x83\xaf\xee\x20\x15\x12\x72\x43\x6e\xc7\x21\x58\x8c\x54\x6f\x0d\x2d\x18\x62\xa5\x22\xb0\x6b\x34\x54\x91\xb1\x0b\x50\xa3\x65\x62\xbb\xdb\x47\x4a\x1c\xfd\xa9\x30\x4b\x99\x37\x65\x8c\xb3\x5d\xcf\xdd\xb5\x89\xec\x52\xeb\x66\x94\x65\xdb\xed\x36\xdd\xfe\x94\x0a\xb9\xcc\xee\x86\xc3\x61\x06\xd3\xa0\x0b\x77\x24\xdd\x02\xe5\xdd\xeb\xe1\x80\x94\xf0\xe1\xf5\xf0\xb0\x01\x64\x17\x0d\xfd\xf9\x3e\xae\xa1\x32\x45\x08\xe5\x0c\x8e\x33\xa6\x62\xfa\x2f\x1a\x02\x65\x0e\xfe\x8e\x23\xd3\x3a\x22\xa0\x42\xf1\x2e\x93\x99\x39\x13\xc8\xb6\x17\x29\x4a\xc6\x97\xa5\x06\x92\x32\x39\x88\x07\x4d\x11\xc4\xf3\x06\x46\x03\xa4\x90\xbd\x1f\xa0\x1e\x1d\x6a\x9f\xc4\x98\xb6\x84\xc6\xa0\xf0\x31\x86\x9f\x01\x91\x18\xc6\x01\x58\x63\x4a\x8a\x45\x59\xe5\x4d\x80\x60\x1c\xe9\x83\x06\x49\x11\x6c\x0b\xce\xb2\x46\x77\xa9\x88\xae\x86\x1f\x20\x7d\x49\xe2\x2d\xc9\x48\xec\xea\x14\xac\x27\x89\x4f\x79\x17\x06\x4f\x5d\x5d\xb8\x35\x8e\x1d\x18\xbf\x7b\x0c\xe8\xeb\x46\xe4\x97\x76\xe9\x2e\x49\x02\x72\x17\xa1\x81\xe4\x66\x9a\xff\x6f\x6e\xf2\x63\xbf\x77\x02\x7e\x3e\xb2\xb1\x95\x17\x6c\x0c\xa6\x48\xff\x80\x2f\x71\x44\xa2\x24\x70\x49\xde\x34\xd0\xd3\xde\x97\xbc\xa2\x31\xf2\x27\xbd\xd0\x06\x8a\xd3\xe0\xf4\x7d\x41\xc5\x52\x08\x0d\x89\x8c\xd7\x9a\x01\x76\x80\x01\x59\x0d\xe0\xee\xb1\x85\x7a\xc6\x58\x57\x95\x91\xaa\x77\x0e\x54\x80\xa8\xbc\x8d\x1c\xa7\x97\xe6\xb7\xf9\x1f\xd0\x41\x52\xe8\x28\x2a\x5e\x25\xa9\x82\xde\x19\x27\xa7\xce\xc7\xd6\xd2\x77\x1d\xe6\xf9\xea\x01\xd7\xbb\x2a\x8c\xcb\x00\x84\x16\xe4\x12\x4b\x1d\x6c\xfa\x3d\x14\xed\x01\x36\xbf\x61\x79\x90\x6b\x16\xf2\x18\xe7\xc3\xba\xeb\x59\x96\xec\xcf\x3f\xc9\x43\x0f\xb9\x91\x6c\x03\x44\xd8\xc4\x0c\xc5\x38\x68\x1c\xb8\x17\x36\x0d\x93\x72\xac\x40\xd0\x18\x2c\x03\xae\x47\x8a\x34\x87\xd2\x97\x41\x31\x1e\x0e\xc7\x1d\x29\xb2\x1b\xd2\x19\x19\x86\x10\x3e\x0f\x9a\xb5\x2a\xe3\x2e\xe0\x21\xb5\x75\x6a\xbb\xb2\xc3\x34\x5f\x10\x16\x41\x92\x03\x6e\x7b\xd4\x9a\xf0\x9c\x5e\x94\xcf\x5c\xfb\xed\x35\x3b\x67\x09\x4b\xa8\x2a\x5e\xb0\xe3\xd4\x70\x4c\xc7\x9d\xaf\x67\x3b\x07\xe0\x69\x3a\x93\x61\xf3\xcf\xf5\xc8\x06\x8b\x11\x7c\xe4\xd5\x69\xcd\x00\x70\x39\x70\xee\x20\x70\x4c\x10\x24\x3d\x9f\x00\x22\x6e\x37\x51\x72\xec\xd0\x4e\x0b\x27\xf9\x5b\x3b\x35\x18\x4b\xc2\xd0\x53\x53\xab\xd8\xc3\x71\xe6\x7f\xb3\x05\x3a\xb3\x05\xfd\x26\xf2\xb8\x4d\x4f\xa6\xa3\x14\xfd\x0a\x0d\xe7\xb3\xa0\xd0\x8d\xf1\x0c\xed\x9d\x82\x9d\xc0\x5e\x39\xb0\x2a\xd8\x4e\xae\x53\x65\xef\x1d\xb6\x5c\x98\x1d\x23\x2f\x12\x47\x07\xf5\xfa\xe7\x85\xad\xa1\x23\x01\xac\xee\xa2\x61\xc2\xf5\x4c\xe2\x6c\x7a\x35\xef\x18\x16\x6d\x37\x97\xc1\xa9\x3f\xaa\x67\xcc\x41\x5e\x5b\xf1\x48\x8f\xdd\x66\x8a\x77\x8b\x93\x2e\x0a\xf1\x65\xda\xab\xf6\x6e\xcd\x5c\x94\x60\x75\x73\x49\x92\x46\x1e\x62\xee\x50\x84\x8f\x63\xa5\xe9\x55\xd5\x7e\x05\xb3\x63\x7c\x7f\x94\xc2\xe4\x9c\x9a\x41\x2a\x85\x8f\x71\x50\x8b\x11\xda\xa9\x01\x57\x04\xaf\x81\x39\x90\xe3\x00\xe6\x1f\x42\x5c\xdc\x5e\x2e\x77\x51\xff\x89\x24\x3a\x57\xf9\xa0\x51\x19\xa2\x7f\x61\x1a\x5d\xae\x82\x96\xe8\xe0\xce\xcb\xa9\xe2\x28\x7b\x59\x61\xae\x3c\x4e\x25\xf3\xf9\xe0\x96\xde\xf9\x0f\xf6\xf3\xb7\xd3\xf3\xcd\x0b\xc9\x51\x40\x19\x64\xef\x70\x2d\x43\x57\x6b\x79\x22\x9c\xa6\x28\x5c\x37\x74\x1d\x47\x8e\xa6\x1e\x88\x46\xff\x53\x50\xa9\xb4\xc0\xc7\x1a\xaf\x02\xa6\xc5\xf9\x38\x33\xaa\x04\xa2\xd2\x4b\xc1\x76\xa2\x86\xa6\xe1\x1e\xda\xb1\xbf\xfd\x43\x71\x6a\xf8\x82\x58\x3d\x0c\x6a\xd0\xa7\xba\x3b\x12\x39\xe9\xba\x91\x7b\x38\xc2\xb8\xda\x8e\xe0\xb7\x4a\xb7\xf6\x8d\x28\x6c\xc1\x17\x78\xbb\xe7\x15\x33\xc7\x59\x6e\x69\x5f\x52\x4e\xb9\xc3\xf0\x74\xc5\xfd\x4c\x44\xc2\x76\xff\x0a\xf5\xac\x6b\xb4\x61\x90\x50\x56\x31\x28\x71\x61\x93\x18\x1f\x6f\x9e\x34\xd6\xb6\x6f\x9d\xef\x64\x98\x7f\x1f\xe9\x72\xeb\xbb\xe1\x0e\xd9\xe6\x19\x7c\xd9\xb4\x9d\x2e\x01\x6b\xc0\x85\x5d\xb1\x73\xb9\xb7\x38\x1a\x3f\xce\x85\x3c\x1a\x60\x91\x32\xbc\xbc\x86\xda\x43\x00\x99\xf1\x19\x5f\x4d\xb0\x72\xb8\xf7\x97\xe3\x16\xda\x1b\x1a\xfc\xf5\x0e\xe0\xf0\x31\x04\xef\x1f\xc1\x9a\x6f\x21\xc7\xe3\x44\xd0\xfc\xfc\x4c\x71\xa6\xf1\x2d\xdc\x04\xe1\x66\x86\xf3\xfd\xee\x61\x91\xda\x58\xc3\x9b\xe3\xba\x31\xbb\x18\x40\x60\xef\x45\x6a\x9f\xe3\x0c\xae\x03\x4b\x06\xe6\xe0\x81\xeb\x72\x5e\x6c\x24\x36\xf6\x40\x4b\x47\xe2\x31\x3a\x53\x55\x36\x47\xb6\x3a\x5b\x33\x36\xc9\x99\xb4\xfa\x4e\xf6\xb5\x61\xc1\x3e\x97\x79\x5d\x9c\x88\x8a\x86\x33\x37\xdc\x63\xdd\xc0\xfd\x6e\xf7\x37\x1a\xfb\x32\xef\xe0\x80\x38\x6d\x72\x89\xe3\x38\xb4\x41\x88\x97\xa6\xca\x0b\x66\x91\x6d\x96\x01\xc5\xa1\xf7\x98\x1b\xae\x72\xd7\xf5\xe0\xa9\x26\x8e\x8e\x1f\x7d\xec\x01\x30\x89\x88\x7a\x05\xab\xf8\xfe\x34\xed\x5a\x57\x1c\xbc\xed\x98\x1e\xf6\xf7\xfb\xdf\x3e\xa3\x1c\x10\xaa\x2c\xa5\xb9\xce\xdd\x95\x7b\x7c\xdd\x26\xd8\xc4\x26\x99\x7f\xd0\x9a\x64\xee\xfd\x3e\xb3\xff\xad\xf8\x0f\x72\xf9\x62\x02\xb5\x18\x00\x00")
+
+func dataStatusHtmlBytes() ([]byte, error) {
+	return bindataRead(
+		_dataStatusHtml,
+		"data/status.html",
+	)
+}
+
+func dataStatusHtml() (*asset, error) {
+	bytes, err := dataStatusHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "data/status.html", size: 6325, mode: os.FileMode(436), modTime: time.Unix(1792197849, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+// Asset loads and returns the asset for the given name.
+// It returns an error if the asset could not be found or
+// could not be loaded.
+func Asset(name string) ([]byte, error) {
+	cannonicalName := strings.Replace(name, "\\", "/", -1)
+	if f, ok := _bindata[cannonicalName]; ok {
+		a, err := f()
+		if err != nil {
+			return nil, fmt.Errorf("Asset %s can't read by error: %v", name, err)
+		}
+		return a.bytes, nil
+	}
+	return nil, fmt.Errorf("Asset %s not found", name)
+}
+
+// MustAsset is like Asset but panics when Asset would return an error.
+// It simplifies safe initialization of global variables.
+func MustAsset(name string) []byte {
+	a, err := Asset(name)
+	if err != nil {
+		panic("asset: Asset(" + name + "): " + err.Error())
+	}
+
+	return a
+}
+
+// AssetInfo loads and returns the asset info for the given name.
+// It returns an error if the asset could not be found or
+// could not be loaded.
+func AssetInfo(name string) (os.FileInfo, error) {
+	cannonicalName := strings.Replace(name, "\\", "/", -1)
+	if f, ok := _bindata[cannonicalName]; ok {
+		a, err := f()
+		if err != nil {
+			return nil, fmt.Errorf("AssetInfo %s can't read by error: %v", name, err)
+		}
+		return a.info, nil
+	}
+	return nil, fmt.Errorf("AssetInfo %s not found", name)
+}
+
+// AssetNames returns the names of the assets.
+func AssetNames() []string {
+	names := make([]string, 0, len(_bindata))
+	for name := range _bindata {
+		names = append(names, name)
+	}
+	return names
+}
+
+// _bindata is a table, holding each asset generator, mapped to its name.
+var _bindata = map[string]func() (*asset, error){
+	"data/status.html": dataStatusHtml,
+}
+
+// AssetDir returns the file names below a certain
+// directory embedded in the file by go-bindata.
+// For example if you run go-bindata on data/... and data contains the
+// following hierarchy:
+//     data/
+//       foo.txt
+//       img/
+//         a.png
+//         b.png
+// then AssetDir("data") would return []string{"foo.txt", "img"}
+// AssetDir("data/img") would return []string{"a.png", "b.png"}
+// AssetDir("foo.txt") and AssetDir("notexist") would return an error
+// AssetDir("") will return []string{"data"}.
+func AssetDir(name string) ([]string, error) {
+	node := _bintree
+	if len(name) != 0 {
+		cannonicalName := strings.Replace(name, "\\", "/", -1)
+		pathList := strings.Split(cannonicalName, "/")
+		for _, p := range pathList {
+			node = node.Children[p]
+			if node == nil {
+				return nil, fmt.Errorf("Asset %s not found", name)
+			}
+		}
+	}
+	if node.Func != nil {
+		return nil, fmt.Errorf("Asset %s not found", name)
+	}
+	rv := make([]string, 0, len(node.Children))
+	for childName := range node.Children {
+		rv = append(rv, childName)
+	}
+	return rv, nil
+}
+
+type bintree struct {
+	Func     func() (*asset, error)
+	Children map[string]*bintree
+}
+var _bintree = &bintree{nil, map[string]*bintree{
+	"data": &bintree{nil, map[string]*bintree{
+		"status.html": &bintree{dataStatusHtml, map[string]*bintree{}},
+	}},
+}}
+
+// RestoreAsset restores an asset under the given directory
+func RestoreAsset(dir, name string) error {
+	data, err := Asset(name)
+	if err != nil {
+		return err
+	}
+	info, err := AssetInfo(name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(_filePath(dir, filepath.Dir(name)), os.FileMode(0755))
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(_filePath(dir, name), data, info.Mode())
+	if err != nil {
+		return err
+	}
+	err = os.Chtimes(_filePath(dir, name), info.ModTime(), info.ModTime())
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RestoreAssets restores an asset under the given directory recursively
+func RestoreAssets(dir, name string) error {
+	children, err := AssetDir(name)
+	// File
+	if err != nil {
+		return RestoreAsset(dir, name)
+	}
+	// Dir
+	for _, child := range children {
+		err = RestoreAssets(dir, filepath.Join(name, child))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func _filePath(dir, name string) string {
+	cannonicalName := strings.Replace(name, "\\", "/", -1)
+	return filepath.Join(append([]string{dir}, strings.Split(cannonicalName, "/")...)...)
+}
+
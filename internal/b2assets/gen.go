@@ -1,3 +0,0 @@
-package b2assets
-
-//go:generate go-bindata -pkg $GOPACKAGE -o b2assets.go data/
@@ -0,0 +1,233 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package format renders object information for the commands.
+//
+// Besides plain text, objects can be written as JSON, either as a single
+// array ("json") or as one object per line ("jsonl").  Each object has the
+// following fields, all but name and the size of an object omitted when empty:
+//
+//	name           the object's name, or a prefix ending in the delimiter
+//	prefix         true for prefixes (directories), which have no other fields
+//	size           size in bytes
+//	content_type   MIME type
+//	version_id     the version of the object (B2 file ID, GCS generation)
+//	hider          true for B2 hide markers
+//	uploaded       when the object was uploaded, in RFC 3339 format
+//	last_modified  when the object's contents last changed, in RFC 3339 format
+//	sha1           checksums, in lowercase hex
+//	md5
+//	crc32c
+//	metadata       user metadata (B2 file info, GCS metadata) as an object
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/kurin/cloudpipe/backend"
+)
+
+// Size returns s as a human-readable string, such as "1.50MB".
+func Size(s int64) string {
+	sfxs := "BkMGT"
+	f := float64(s)
+	for i := 0; i < 5; i++ {
+		if f < 1024 {
+			return fmt.Sprintf("%.2f%c", f, sfxs[i])
+		}
+		f /= 1024
+	}
+	return fmt.Sprintf("%dB", s)
+}
+
+// Attrs writes a as aligned "key: value" lines.  Fields that the backend
+// didn't report are left out, and user metadata follows in key order.
+func Attrs(w io.Writer, a *backend.Attrs) error {
+	kv := map[string]string{
+		"Name":         a.Name,
+		"Size":         Size(a.Size),
+		"Content-Type": a.ContentType,
+	}
+	order := []string{"Name", "Size", "Content-Type"}
+	add := func(key, val string) {
+		if val == "" {
+			return
+		}
+		kv[key] = val
+		order = append(order, key)
+	}
+	if !a.Uploaded.IsZero() {
+		add("Uploaded", a.Uploaded.Format(time.RubyDate))
+	}
+	if !a.LastModified.IsZero() {
+		add("Last Modified", a.LastModified.Format(time.RubyDate))
+	}
+	add("Version", a.Version)
+	add("SHA1", a.SHA1)
+	add("MD5", a.MD5)
+	add("CRC32C", a.CRC32C)
+
+	var keys []string
+	for key := range a.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add(key, a.Metadata[key])
+	}
+
+	var max int
+
+	for key := range kv {
+		if len(key) > max {
+			max = len(key)
+		}
+	}
+
+	for _, key := range order {
+		if _, err := fmt.Fprintf(w, "%*s: %s\n", max, key, kv[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The output formats understood by commands.
+const (
+	Text  = "text"
+	JSON  = "json"
+	JSONL = "jsonl"
+)
+
+// Check returns an error if f isn't a known output format.
+func Check(f string) error {
+	switch f {
+	case Text, JSON, JSONL:
+		return nil
+	}
+	return fmt.Errorf("%s: unknown format; want %s, %s, or %s", f, Text, JSON, JSONL)
+}
+
+type jsonAttrs struct {
+	Name         string            `json:"name"`
+	Prefix       bool              `json:"prefix,omitempty"`
+	Size         *int64            `json:"size,omitempty"`
+	ContentType  string            `json:"content_type,omitempty"`
+	Version      string            `json:"version_id,omitempty"`
+	Hider        bool              `json:"hider,omitempty"`
+	Uploaded     *time.Time        `json:"uploaded,omitempty"`
+	LastModified *time.Time        `json:"last_modified,omitempty"`
+	SHA1         string            `json:"sha1,omitempty"`
+	MD5          string            `json:"md5,omitempty"`
+	CRC32C       string            `json:"crc32c,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+func toJSON(a *backend.Attrs) *jsonAttrs {
+	j := &jsonAttrs{
+		Name:        a.Name,
+		Prefix:      a.Prefix,
+		ContentType: a.ContentType,
+		Version:     a.Version,
+		Hider:       a.Hider,
+		SHA1:        a.SHA1,
+		MD5:         a.MD5,
+		CRC32C:      a.CRC32C,
+		Metadata:    a.Metadata,
+	}
+	if !a.Prefix {
+		// Empty objects still report their size; prefixes have none.
+		size := a.Size
+		j.Size = &size
+	}
+	if !a.Uploaded.IsZero() {
+		t := a.Uploaded.UTC()
+		j.Uploaded = &t
+	}
+	if !a.LastModified.IsZero() {
+		t := a.LastModified.UTC()
+		j.LastModified = &t
+	}
+	return j
+}
+
+// Object writes a single object in format f.  Text is written with Attrs,
+// JSON as an indented object, and JSONL as a single line.
+func Object(w io.Writer, f string, a *backend.Attrs) error {
+	switch f {
+	case JSON:
+		b, err := json.MarshalIndent(toJSON(a), "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	case JSONL:
+		return json.NewEncoder(w).Encode(toJSON(a))
+	}
+	return Attrs(w, a)
+}
+
+// A List writes a stream of objects.  In JSON format the objects form a
+// single array, which Close terminates.
+type List struct {
+	w    io.Writer
+	f    string
+	text func(io.Writer, *backend.Attrs) error
+	n    int
+}
+
+// NewList returns a List that writes objects in format f.  Text output is
+// delegated to text.
+func NewList(w io.Writer, f string, text func(io.Writer, *backend.Attrs) error) *List {
+	return &List{w: w, f: f, text: text}
+}
+
+// Write writes a single object.
+func (l *List) Write(a *backend.Attrs) error {
+	defer func() { l.n++ }()
+	switch l.f {
+	case JSON:
+		sep := ",\n  "
+		if l.n == 0 {
+			sep = "[\n  "
+		}
+		b, err := json.Marshal(toJSON(a))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(l.w, "%s%s", sep, b)
+		return err
+	case JSONL:
+		return json.NewEncoder(l.w).Encode(toJSON(a))
+	}
+	return l.text(l.w, a)
+}
+
+// Close finishes the output.  It must be called even if nothing was written.
+func (l *List) Close() error {
+	if l.f != JSON {
+		return nil
+	}
+	end := "\n]\n"
+	if l.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(l.w, end)
+	return err
+}
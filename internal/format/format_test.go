@@ -0,0 +1,140 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kurin/cloudpipe/backend"
+)
+
+func TestSize(t *testing.T) {
+	table := []struct {
+		s    int64
+		want string
+	}{
+		{
+			s:    12,
+			want: "12.00B",
+		},
+		{
+			s:    1024,
+			want: "1.00k",
+		},
+		{
+			s:    1024 * 1024,
+			want: "1.00M",
+		},
+		{
+			s:    1536 * 1024,
+			want: "1.50M",
+		},
+		{
+			s:    45548132761, // 42.42 * 1024 * 1024 * 1024
+			want: "42.42G",
+		},
+	}
+
+	for _, ent := range table {
+		if got := Size(ent.s); got != ent.want {
+			t.Errorf("Size(%d): got %q, want %q", ent.s, got, ent.want)
+		}
+	}
+}
+
+func TestAttrs(t *testing.T) {
+	a := &backend.Attrs{
+		Name:     "foo",
+		Size:     1024,
+		SHA1:     "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+		Metadata: map[string]string{"b": "2", "a": "1"},
+	}
+	want := "        Name: foo\n" +
+		"        Size: 1.00k\n" +
+		"Content-Type: \n" +
+		"        SHA1: da39a3ee5e6b4b0d3255bfef95601890afd80709\n" +
+		"           a: 1\n" +
+		"           b: 2\n"
+	buf := &bytes.Buffer{}
+	if err := Attrs(buf, a); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestListJSON(t *testing.T) {
+	objs := []*backend.Attrs{
+		{
+			Name:     "a/b",
+			Size:     10,
+			Uploaded: time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC),
+			Metadata: map[string]string{"k": "v"},
+		},
+		{
+			Name:   "a/c/",
+			Prefix: true,
+		},
+		{
+			Name: "a/d",
+		},
+	}
+
+	table := []struct {
+		f    string
+		objs []*backend.Attrs
+		want string
+	}{
+		{
+			f:    JSON,
+			want: "[]\n",
+		},
+		{
+			f:    JSON,
+			objs: objs,
+			want: "[\n" +
+				`  {"name":"a/b","size":10,"uploaded":"2017-03-01T12:00:00Z","metadata":{"k":"v"}},` + "\n" +
+				`  {"name":"a/c/","prefix":true},` + "\n" +
+				`  {"name":"a/d","size":0}` + "\n" +
+				"]\n",
+		},
+		{
+			f:    JSONL,
+			objs: objs,
+			want: `{"name":"a/b","size":10,"uploaded":"2017-03-01T12:00:00Z","metadata":{"k":"v"}}` + "\n" +
+				`{"name":"a/c/","prefix":true}` + "\n" +
+				`{"name":"a/d","size":0}` + "\n",
+		},
+	}
+
+	for _, ent := range table {
+		buf := &bytes.Buffer{}
+		l := NewList(buf, ent.f, nil)
+		for _, obj := range ent.objs {
+			if err := l.Write(obj); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != ent.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", ent.f, buf.String(), ent.want)
+		}
+	}
+}
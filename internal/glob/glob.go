@@ -0,0 +1,101 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package glob matches slash-separated object names against shell patterns.
+// Patterns follow path.Match, and in addition a "**" element matches any
+// number of path elements, including none, so that "**/*.tar.gz" matches
+// both "a.tar.gz" and "a/b/c.tar.gz".
+package glob
+
+import (
+	"path"
+	"strings"
+)
+
+// Check returns path.ErrBadPattern if pattern is malformed.
+func Check(pattern string) error {
+	for _, elem := range strings.Split(pattern, "/") {
+		if _, err := path.Match(elem, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match reports whether name matches pattern.  The only possible error is
+// path.ErrBadPattern.
+func Match(pattern, name string) (bool, error) {
+	return match(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func match(pats, elems []string) (bool, error) {
+	for len(pats) > 0 {
+		if pats[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				ok, err := match(pats[1:], elems[i:])
+				if ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(elems) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pats[0], elems[0])
+		if !ok || err != nil {
+			return false, err
+		}
+		pats, elems = pats[1:], elems[1:]
+	}
+	return len(elems) == 0, nil
+}
+
+// List is a flag.Value that collects every pattern it is given.
+type List []string
+
+func (l *List) String() string { return strings.Join(*l, ",") }
+
+func (l *List) Set(s string) error {
+	if err := Check(s); err != nil {
+		return err
+	}
+	*l = append(*l, s)
+	return nil
+}
+
+// A Filter selects names that match at least one Include pattern, or any
+// name if there are none, and no Exclude pattern.  The patterns must already
+// have been checked.
+type Filter struct {
+	Include List
+	Exclude List
+}
+
+// Match reports whether the filter selects name.
+func (f *Filter) Match(name string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, name) {
+		return false
+	}
+	return !matchAny(f.Exclude, name)
+}
+
+func matchAny(pats []string, name string) bool {
+	for _, pat := range pats {
+		if ok, _ := Match(pat, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	table := []struct {
+		pat, name string
+		want      bool
+	}{
+		{pat: "*.tar.gz", name: "a.tar.gz", want: true},
+		{pat: "*.tar.gz", name: "x/a.tar.gz", want: false},
+		{pat: "**/*.tar.gz", name: "a.tar.gz", want: true},
+		{pat: "**/*.tar.gz", name: "x/y/a.tar.gz", want: true},
+		{pat: "**/*.tar.gz", name: "x/y/a.tar", want: false},
+		{pat: "x/**", name: "x/y/z", want: true},
+		{pat: "x/**", name: "y/x/z", want: false},
+		{pat: "x/**/z", name: "x/z", want: true},
+		{pat: "x/**/z", name: "x/a/b/z", want: true},
+		{pat: "x/?", name: "x/ab", want: false},
+	}
+
+	for _, ent := range table {
+		got, err := Match(ent.pat, ent.name)
+		if err != nil {
+			t.Errorf("Match(%q, %q): %v", ent.pat, ent.name, err)
+			continue
+		}
+		if got != ent.want {
+			t.Errorf("Match(%q, %q): got %v, want %v", ent.pat, ent.name, got, ent.want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f := &Filter{
+		Include: List{"**/*.gz"},
+		Exclude: List{"tmp/**"},
+	}
+	table := map[string]bool{
+		"a.gz":     true,
+		"b/a.gz":   true,
+		"tmp/a.gz": false,
+		"a.txt":    false,
+	}
+	for name, want := range table {
+		if got := f.Match(name); got != want {
+			t.Errorf("Match(%q): got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestBadPattern(t *testing.T) {
+	var l List
+	if err := l.Set("a/[/b"); err == nil {
+		t.Error("Set(\"a/[/b\"): got nil error")
+	}
+}
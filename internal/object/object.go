@@ -0,0 +1,88 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package object copies and removes single objects, for the commands that
+// work on them.
+package object
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/kurin/cloudpipe/backend"
+)
+
+// Copy copies the contents of src to dst, as Write does.
+func Copy(ctx context.Context, src, dst backend.Endpoint) error {
+	srcR, ok := src.(backend.Reader)
+	if !ok {
+		return backend.UnsupportedError{Op: "read"}
+	}
+	r, err := srcR.Reader(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return Write(ctx, dst, r)
+}
+
+// Write copies r to dst.  If reading or writing fails, the write is abandoned
+// rather than committing what was written of it.
+func Write(ctx context.Context, dst backend.Endpoint, r io.Reader) error {
+	dstW, ok := dst.(backend.Writer)
+	if !ok {
+		return backend.UnsupportedError{Op: "write"}
+	}
+	// Cancelling wctx before closing w abandons the write.
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w, err := dstW.Writer(wctx)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		cancel()
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Remove removes the single object ep.
+func Remove(ctx context.Context, ep backend.Endpoint) error {
+	ns, ok := ep.(backend.Namespace)
+	if !ok || ns.Name() == "" {
+		// Without a name, this might be a whole bucket.
+		return backend.UnsupportedError{Op: "remove"}
+	}
+	rm, ok := ep.(backend.Remover)
+	if !ok {
+		return backend.UnsupportedError{Op: "remove"}
+	}
+	return rm.Remove(ctx)
+}
+
+// DirName returns name with a trailing slash, unless it is empty.  The current
+// directory, ".", is empty too, since local files beneath it are listed
+// without any prefix.
+func DirName(name string) string {
+	if name == "." {
+		return ""
+	}
+	if name == "" || strings.HasSuffix(name, "/") {
+		return name
+	}
+	return name + "/"
+}
@@ -0,0 +1,89 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/kurin/cloudpipe/backend"
+)
+
+// Tree calls fn, from threads goroutines, with the name of each object beneath
+// src, which must be opened recursively, and the name relative to src.  If
+// nothing is beneath src but it may name an object, fn is called for that
+// object alone, relative to its parent.  An error is returned if src can't be
+// listed, or holds nothing.
+func Tree(ctx context.Context, src backend.Namespace, threads int, fn func(name, rel string)) error {
+	// Treat the source as a directory, so that b2://bucket/dir doesn't also
+	// match b2://bucket/directory.
+	base := DirName(src.Name())
+	var ep backend.Endpoint = src
+	if base != "" {
+		ep = src.Object(base)
+	}
+	l, ok := ep.(backend.Lister)
+	if !ok {
+		return backend.UnsupportedError{Op: "list"}
+	}
+	objs, errs, err := l.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	if threads < 1 {
+		threads = 1
+	}
+	var wg sync.WaitGroup
+	names := make(chan string)
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				rel := strings.TrimPrefix(name, base)
+				if rel == name && DirName(name) == base {
+					// The source was a single object.
+					rel = path.Base(name)
+				}
+				fn(name, rel)
+			}
+		}()
+	}
+
+	var listed int
+	for obj := range objs {
+		if obj.Prefix || obj.Hider {
+			continue
+		}
+		listed++
+		names <- obj.Name
+	}
+	err = <-errs
+	if err == nil && listed == 0 {
+		if name := src.Name(); name != "" && !strings.HasSuffix(name, "/") {
+			// Nothing is beneath the path, but it may name an object.
+			names <- name
+		} else {
+			err = errors.New("no objects")
+		}
+	}
+	close(names)
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,133 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+
+	"github.com/kurin/cloudpipe/backend"
+)
+
+// A Verifier hashes the data written to it with each checksum its destination
+// reports, and checks the results against the destination once it has been
+// written.
+type Verifier struct {
+	dst    backend.Statter
+	names  []string
+	hashes []hash.Hash
+	size   int64
+}
+
+// NewVerifier returns a Verifier for dst, which must report its attributes.
+func NewVerifier(dst backend.Endpoint) (*Verifier, error) {
+	st, ok := dst.(backend.Statter)
+	if !ok {
+		return nil, backend.UnsupportedError{Op: "verify"}
+	}
+	v := &Verifier{dst: st}
+	if cs, ok := dst.(backend.Checksummer); ok {
+		for _, name := range cs.Checksums() {
+			h, err := backend.NewHash(name)
+			if err != nil {
+				return nil, err
+			}
+			v.names = append(v.names, name)
+			v.hashes = append(v.hashes, h)
+		}
+	}
+	return v, nil
+}
+
+func (v *Verifier) Write(p []byte) (int, error) {
+	for _, h := range v.hashes {
+		h.Write(p)
+	}
+	v.size += int64(len(p))
+	return len(p), nil
+}
+
+// Check compares the size and checksums of the destination with what was
+// written.  Checksums that the destination doesn't report for this object
+// are skipped; if none are left, a warning is printed and only the size is
+// checked.
+func (v *Verifier) Check(ctx context.Context) error {
+	a, err := v.dst.Stat(ctx)
+	if err != nil {
+		return fmt.Errorf("verify: %v", err)
+	}
+	if a.Size != v.size {
+		return fmt.Errorf("verify: %s: read %d bytes, but destination has %d", a.Name, v.size, a.Size)
+	}
+	var checked bool
+	for i, name := range v.names {
+		want := hex.EncodeToString(v.hashes[i].Sum(nil))
+		got := a.Checksum(name)
+		if got == "" {
+			continue
+		}
+		if got != want {
+			return fmt.Errorf("verify: %s: %s mismatch: read %s, but destination has %s", a.Name, name, want, got)
+		}
+		checked = true
+	}
+	if !checked {
+		fmt.Fprintf(os.Stderr, "verify: %s: destination reports no checksum; verified size only\n", a.Name)
+	}
+	return nil
+}
+
+// Compare checks that src and dst report the same size, and the same value
+// for every checksum they both report.
+func Compare(ctx context.Context, src, dst backend.Endpoint) error {
+	srcSt, ok := src.(backend.Statter)
+	if !ok {
+		return backend.UnsupportedError{Op: "verify"}
+	}
+	dstSt, ok := dst.(backend.Statter)
+	if !ok {
+		return backend.UnsupportedError{Op: "verify"}
+	}
+	sa, err := srcSt.Stat(ctx)
+	if err != nil {
+		return fmt.Errorf("verify: %v", err)
+	}
+	da, err := dstSt.Stat(ctx)
+	if err != nil {
+		return fmt.Errorf("verify: %v", err)
+	}
+	if sa.Size != da.Size {
+		return fmt.Errorf("verify: %s: source has %d bytes, but destination has %d", da.Name, sa.Size, da.Size)
+	}
+	for _, name := range []string{"sha1", "md5", "crc32c"} {
+		want, got := sa.Checksum(name), da.Checksum(name)
+		if want != "" && got != "" && want != got {
+			return fmt.Errorf("verify: %s: %s mismatch: source has %s, but destination has %s", da.Name, name, want, got)
+		}
+	}
+	return nil
+}
+
+// RemoveBad removes dst, which failed verification with verr, and returns verr
+// with the outcome.
+func RemoveBad(ctx context.Context, dst backend.Endpoint, verr error) error {
+	if err := Remove(ctx, dst); err != nil {
+		return fmt.Errorf("%v; removing it failed: %v", verr, err)
+	}
+	return fmt.Errorf("%v; removed it", verr)
+}
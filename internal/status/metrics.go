@@ -0,0 +1,105 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+func serveJSON(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(rw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(Current()); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func serveMetrics(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetrics(rw, Current())
+}
+
+// writeMetrics writes s in the Prometheus text exposition format.
+func writeMetrics(w io.Writer, s *Snapshot) {
+	fmt.Fprintln(w, "# HELP cloudpipe_bytes_written_total Bytes uploaded, by backend.")
+	fmt.Fprintln(w, "# TYPE cloudpipe_bytes_written_total counter")
+	for _, be := range keys(s.BytesWritten) {
+		fmt.Fprintf(w, "cloudpipe_bytes_written_total{backend=%s} %d\n", quote(be), s.BytesWritten[be])
+	}
+
+	fmt.Fprintln(w, "# HELP cloudpipe_bytes_read_total Bytes downloaded, by backend.")
+	fmt.Fprintln(w, "# TYPE cloudpipe_bytes_read_total counter")
+	for _, be := range keys(s.BytesRead) {
+		fmt.Fprintf(w, "cloudpipe_bytes_read_total{backend=%s} %d\n", quote(be), s.BytesRead[be])
+	}
+
+	fmt.Fprintln(w, "# HELP cloudpipe_transfers_active Uploads and downloads in progress.")
+	fmt.Fprintln(w, "# TYPE cloudpipe_transfers_active gauge")
+	fmt.Fprintf(w, "cloudpipe_transfers_active{direction=\"upload\"} %d\n", len(s.Writers))
+	fmt.Fprintf(w, "cloudpipe_transfers_active{direction=\"download\"} %d\n", len(s.Readers))
+
+	fmt.Fprintln(w, "# HELP cloudpipe_requests_total Backend calls, by method.")
+	fmt.Fprintln(w, "# TYPE cloudpipe_requests_total counter")
+	for _, method := range keys(s.Calls) {
+		fmt.Fprintf(w, "cloudpipe_requests_total{method=%s} %d\n", quote(method), s.Calls[method])
+	}
+
+	fmt.Fprintln(w, "# HELP cloudpipe_request_duration_seconds Backend call latency, by method.")
+	fmt.Fprintln(w, "# TYPE cloudpipe_request_duration_seconds histogram")
+	for _, method := range keys(s.MethodHist) {
+		var n int
+		for i, count := range s.MethodHist[method] {
+			n += count
+			le := (math.Exp2(float64(i+1)) - 1) / 1000
+			fmt.Fprintf(w, "cloudpipe_request_duration_seconds_bucket{method=%s,le=\"%g\"} %d\n", quote(method), le, n)
+		}
+		fmt.Fprintf(w, "cloudpipe_request_duration_seconds_bucket{method=%s,le=\"+Inf\"} %d\n", quote(method), n)
+		fmt.Fprintf(w, "cloudpipe_request_duration_seconds_sum{method=%s} %g\n", quote(method), s.Latency[method])
+		fmt.Fprintf(w, "cloudpipe_request_duration_seconds_count{method=%s} %d\n", quote(method), n)
+	}
+}
+
+// quote returns s as a Prometheus label value.
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func keys(m interface{}) []string {
+	var ks []string
+	switch m := m.(type) {
+	case map[string]int64:
+		for k := range m {
+			ks = append(ks, k)
+		}
+	case map[string]int:
+		for k := range m {
+			ks = append(ks, k)
+		}
+	case map[string][]int:
+		for k := range m {
+			ks = append(ks, k)
+		}
+	}
+	sort.Strings(ks)
+	return ks
+}
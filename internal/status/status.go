@@ -0,0 +1,131 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package status tracks the transfers and backend calls in progress, and
+// runs the HTTP server that reports on them.  Every backend reports into it;
+// it serves only if Start is called.
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kurin/cloudpipe/internal/assets"
+	"github.com/kurin/cloudpipe/internal/format"
+)
+
+var (
+	mux = http.NewServeMux()
+
+	mu      sync.Mutex
+	started bool
+
+	statusFuncMap = template.FuncMap{
+		"inc": func(i int) int { return i + 1 },
+		"pRange": func(i int) string {
+			f := float64(i)
+			min := int(math.Pow(2, f)) - 1
+			max := min + int(math.Pow(2, f))
+			return fmt.Sprintf("%v - %v", time.Duration(min)*time.Millisecond, time.Duration(max)*time.Millisecond)
+		},
+		"lookUp": func(m map[string]int, s string) int {
+			return m[s]
+		},
+		"size": format.Size,
+		"newest": func(fs []*Finished) []*Finished {
+			r := make([]*Finished, len(fs))
+			for i, f := range fs {
+				r[len(fs)-1-i] = f
+			}
+			return r
+		},
+	}
+	statusTemplate = template.Must(template.New("status").Funcs(statusFuncMap).Parse(string(assets.MustAsset("data/status.html"))))
+)
+
+func init() {
+	mux.Handle("/progress", http.HandlerFunc(serveStatus))
+	mux.Handle("/progress.json", http.HandlerFunc(serveJSON))
+	mux.Handle("/metrics", http.HandlerFunc(serveMetrics))
+	mux.Handle("/progress/events", http.HandlerFunc(serveEvents))
+}
+
+// eventInterval is how often snapshots are sent to event stream clients.
+const eventInterval = time.Second
+
+// serveEvents streams a JSON snapshot every eventInterval as Server-Sent
+// Events, until the client goes away.
+func serveEvents(rw http.ResponseWriter, r *http.Request) {
+	fl, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+
+	t := time.NewTicker(eventInterval)
+	defer t.Stop()
+	for {
+		b, err := json.Marshal(Current())
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(rw, "data: %s\n\n", b); err != nil {
+			return
+		}
+		fl.Flush()
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func serveStatus(rw http.ResponseWriter, r *http.Request) {
+	if err := statusTemplate.Execute(rw, Current()); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Start listens on addr and serves status pages in the background.  It
+// returns an error if addr can't be bound, or if the server was already
+// started.
+func Start(addr string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if started {
+		return errors.New("status server already started")
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	started = true
+	go func() {
+		if err := http.Serve(l, mux); err != nil {
+			fmt.Fprintf(os.Stderr, "status server: %v\n", err)
+		}
+	}()
+	return nil
+}
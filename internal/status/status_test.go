@@ -0,0 +1,91 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type nopCloser struct{ bytes.Buffer }
+
+func (*nopCloser) Close() error { return nil }
+
+func TestMetrics(t *testing.T) {
+	Observe("test.get", 0)
+	Observe("test.get", 5*time.Millisecond)
+
+	w := TrackWriter("mem://bucket/obj", &nopCloser{}, nil)
+	io.WriteString(w, "hello")
+	s := Current()
+	if len(s.Writers) != 1 {
+		t.Errorf("got %d writers, want 1", len(s.Writers))
+	}
+	w.Close()
+
+	buf := &bytes.Buffer{}
+	writeMetrics(buf, Current())
+	for _, want := range []string{
+		`cloudpipe_bytes_written_total{backend="mem"} 5`,
+		`cloudpipe_transfers_active{direction="upload"} 0`,
+		`cloudpipe_requests_total{method="test.get"} 2`,
+		`cloudpipe_request_duration_seconds_bucket{method="test.get",le="0.001"} 1`,
+		`cloudpipe_request_duration_seconds_bucket{method="test.get",le="0.003"} 1`,
+		`cloudpipe_request_duration_seconds_bucket{method="test.get",le="0.007"} 2`,
+		`cloudpipe_request_duration_seconds_bucket{method="test.get",le="+Inf"} 2`,
+		`cloudpipe_request_duration_seconds_count{method="test.get"} 2`,
+	} {
+		if !strings.Contains(buf.String(), want+"\n") {
+			t.Errorf("metrics missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+type failReader struct{}
+
+func (failReader) Read([]byte) (int, error) { return 0, errors.New("boom") }
+func (failReader) Close() error             { return nil }
+
+func TestFinished(t *testing.T) {
+	for _, name := range []string{"mem://bucket/old", "mem://bucket/bad"} {
+		r := TrackReader(name, failReader{}, nil)
+		io.Copy(ioutil.Discard, r)
+		r.Close()
+	}
+
+	s := Current()
+	if len(s.Finished) == 0 {
+		t.Fatal("no finished transfers")
+	}
+	f := s.Finished[len(s.Finished)-1]
+	if f.Name != "mem://bucket/bad" || f.Upload || f.Error != "boom" {
+		t.Errorf("got %+v, want failed download of mem://bucket/bad", f)
+	}
+
+	rw := httptest.NewRecorder()
+	serveStatus(rw, httptest.NewRequest("GET", "/progress", nil))
+	if !strings.Contains(rw.Body.String(), "boom") {
+		t.Errorf("status page doesn't report the failed transfer:\n%s", rw.Body.String())
+	}
+	if body := rw.Body.String(); strings.Index(body, "mem://bucket/bad") > strings.Index(body, "mem://bucket/old") {
+		t.Errorf("status page doesn't list finished transfers newest first:\n%s", body)
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright 2017, Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot is the state of every transfer in progress, and the latency of
+// every backend call made so far.
+type Snapshot struct {
+	Writers map[string]*Transfer `json:"writers"`
+	Readers map[string]*Transfer `json:"readers"`
+
+	// MethodHist holds, for each method, the number of calls whose latency
+	// fell in each bucket.  Bucket i counts calls that took between 2^i-1
+	// and 2^(i+1)-1 milliseconds.
+	MethodHist map[string][]int `json:"method_hist"`
+
+	// Calls is the number of calls made to each method.
+	Calls map[string]int `json:"calls"`
+
+	// Latency is the total time spent in calls to each method, in seconds.
+	// Some backends report only histograms, and their totals are estimated
+	// from the middle of each bucket.
+	Latency map[string]float64 `json:"latency_seconds"`
+
+	// BytesWritten and BytesRead are the bytes transferred by each backend,
+	// including by transfers that have finished.  Backends are named by URI
+	// scheme, with local files as "file".
+	BytesWritten map[string]int64 `json:"bytes_written"`
+	BytesRead    map[string]int64 `json:"bytes_read"`
+
+	// Finished holds the most recent transfers to have completed or failed,
+	// oldest first.
+	Finished []*Finished `json:"finished"`
+}
+
+// Finished describes an upload or download that has completed or failed.
+type Finished struct {
+	Name    string    `json:"name"`
+	Upload  bool      `json:"upload"`
+	Bytes   int64     `json:"bytes"`
+	Started time.Time `json:"started"`
+	Ended   time.Time `json:"ended"`
+
+	// Error is empty if the transfer succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// Rate returns the transfer's average throughput in bytes per second, or 0 if
+// it took no measurable time.
+func (f *Finished) Rate() int64 {
+	secs := f.Ended.Sub(f.Started).Seconds()
+	if secs <= 0 {
+		return 0
+	}
+	return int64(float64(f.Bytes)/secs + 0.5)
+}
+
+// Transfer describes a single upload or download.
+type Transfer struct {
+	Bytes   int64     `json:"bytes"`
+	Started time.Time `json:"started"`
+
+	// Progress holds the fraction complete of each chunk in flight, for
+	// backends that transfer objects in chunks.
+	Progress []float64 `json:"progress,omitempty"`
+}
+
+type tracker struct {
+	name   string
+	upload bool
+	start  time.Time
+	n      int64 // accessed atomically
+	chunks func() []float64
+
+	errMu sync.Mutex
+	err   error
+}
+
+// fail records the first error other than io.EOF.
+func (t *tracker) fail(err error) {
+	if err == nil || err == io.EOF {
+		return
+	}
+	t.errMu.Lock()
+	defer t.errMu.Unlock()
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+func (t *tracker) transfer() *Transfer {
+	tr := &Transfer{
+		Bytes:   atomic.LoadInt64(&t.n),
+		Started: t.start,
+	}
+	if t.chunks != nil {
+		tr.Progress = t.chunks()
+	}
+	return tr
+}
+
+var (
+	trackMu   sync.Mutex
+	writers   = make(map[*tracker]bool)
+	readers   = make(map[*tracker]bool)
+	hists     = make(map[string][]int)
+	calls     = make(map[string]int)
+	latency   = make(map[string]float64)
+	written   = make(map[string]int64)
+	read      = make(map[string]int64)
+	finished  []*Finished
+	sourceFns []func(*Snapshot)
+)
+
+// maxFinished is the number of finished transfers to remember.
+const maxFinished = 100
+
+// backendName returns the URI scheme of a transfer's name.
+func backendName(name string) string {
+	if i := strings.Index(name, "://"); i > 0 {
+		return name[:i]
+	}
+	return "file"
+}
+
+// Current returns a snapshot of the current state.
+func Current() *Snapshot {
+	s := &Snapshot{
+		Writers:      make(map[string]*Transfer),
+		Readers:      make(map[string]*Transfer),
+		MethodHist:   make(map[string][]int),
+		Calls:        make(map[string]int),
+		Latency:      make(map[string]float64),
+		BytesWritten: make(map[string]int64),
+		BytesRead:    make(map[string]int64),
+	}
+	trackMu.Lock()
+	for be, n := range written {
+		s.BytesWritten[be] = n
+	}
+	for be, n := range read {
+		s.BytesRead[be] = n
+	}
+	for t := range writers {
+		tr := t.transfer()
+		s.Writers[t.name] = tr
+		s.BytesWritten[backendName(t.name)] += tr.Bytes
+	}
+	for t := range readers {
+		tr := t.transfer()
+		s.Readers[t.name] = tr
+		s.BytesRead[backendName(t.name)] += tr.Bytes
+	}
+	for method, hist := range hists {
+		s.MethodHist[method] = append([]int(nil), hist...)
+	}
+	for method, n := range calls {
+		s.Calls[method] = n
+	}
+	for method, sec := range latency {
+		s.Latency[method] = sec
+	}
+	s.Finished = append([]*Finished(nil), finished...)
+	fns := sourceFns
+	trackMu.Unlock()
+
+	for _, fn := range fns {
+		fn(s)
+	}
+	return s
+}
+
+// AddSource registers a function that adds to every snapshot, for backends
+// that keep statistics of their own.
+func AddSource(fn func(*Snapshot)) {
+	trackMu.Lock()
+	defer trackMu.Unlock()
+	sourceFns = append(sourceFns, fn)
+}
+
+// Observe records a call to method that took d.
+func Observe(method string, d time.Duration) {
+	i := 0
+	for ms := int64(d/time.Millisecond) + 1; ms > 1; ms >>= 1 {
+		i++
+	}
+	trackMu.Lock()
+	defer trackMu.Unlock()
+	hist := hists[method]
+	for len(hist) <= i {
+		hist = append(hist, 0)
+	}
+	hist[i]++
+	hists[method] = hist
+	calls[method]++
+	latency[method] += d.Seconds()
+}
+
+// Time starts timing a call to method, which is recorded when the returned
+// function is called.
+func Time(method string) func() {
+	start := time.Now()
+	return func() { Observe(method, time.Since(start)) }
+}
+
+func track(m map[*tracker]bool, name string, upload bool, chunks func() []float64) *tracker {
+	t := &tracker{name: name, upload: upload, start: time.Now(), chunks: chunks}
+	trackMu.Lock()
+	m[t] = true
+	trackMu.Unlock()
+	return t
+}
+
+// untrack moves t to the finished transfers, adding the bytes it moved to
+// total.
+func untrack(m map[*tracker]bool, total map[string]int64, t *tracker) {
+	f := &Finished{
+		Name:    t.name,
+		Upload:  t.upload,
+		Bytes:   atomic.LoadInt64(&t.n),
+		Started: t.start,
+		Ended:   time.Now(),
+	}
+	t.errMu.Lock()
+	if t.err != nil {
+		f.Error = t.err.Error()
+	}
+	t.errMu.Unlock()
+
+	trackMu.Lock()
+	defer trackMu.Unlock()
+	delete(m, t)
+	total[backendName(t.name)] += f.Bytes
+	finished = append(finished, f)
+	if len(finished) > maxFinished {
+		finished = finished[len(finished)-maxFinished:]
+	}
+}
+
+// TrackReader reports r as a download named name until it is closed.  If
+// chunks is not nil, it is called for the progress of each chunk in flight.
+func TrackReader(name string, r io.ReadCloser, chunks func() []float64) io.ReadCloser {
+	return &reader{r: r, t: track(readers, name, false, chunks)}
+}
+
+// TrackWriter reports w as an upload named name until it is closed.  If
+// chunks is not nil, it is called for the progress of each chunk in flight.
+func TrackWriter(name string, w io.WriteCloser, chunks func() []float64) io.WriteCloser {
+	return &writer{w: w, t: track(writers, name, true, chunks)}
+}
+
+type reader struct {
+	r    io.ReadCloser
+	t    *tracker
+	once sync.Once
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	atomic.AddInt64(&r.t.n, int64(n))
+	r.t.fail(err)
+	return n, err
+}
+
+func (r *reader) Close() error {
+	err := r.r.Close()
+	r.t.fail(err)
+	r.once.Do(func() { untrack(readers, read, r.t) })
+	return err
+}
+
+type writer struct {
+	w    io.WriteCloser
+	t    *tracker
+	once sync.Once
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	atomic.AddInt64(&w.t.n, int64(n))
+	w.t.fail(err)
+	return n, err
+}
+
+func (w *writer) Close() error {
+	err := w.w.Close()
+	w.t.fail(err)
+	w.once.Do(func() { untrack(writers, written, w.t) })
+	return err
+}